@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hajimehoshi/ebiten"
+
+	"github.com/fabianvf/pong-golang/pkg/bindings"
+	"github.com/fabianvf/pong-golang/pkg/control"
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+var keysByName = map[string]ebiten.Key{}
+
+func init() {
+	for k := ebiten.Key(0); k <= ebiten.KeyMax; k++ {
+		if name := k.String(); name != "" {
+			keysByName[name] = k
+		}
+	}
+}
+
+func knownInput(name string) bool {
+	if _, ok := keysByName[name]; ok {
+		return true
+	}
+	_, ok := gamepadButtonByName(name)
+	return ok
+}
+
+func keysFor(names []string) []ebiten.Key {
+	var keys []ebiten.Key
+	for _, name := range names {
+		if k, ok := keysByName[name]; ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// loadBindings reads and validates the bindings at path, falling back to the
+// defaults when there is no file yet.
+func loadBindings(path string) (bindings.Bindings, error) {
+	b, err := bindings.Load(path)
+	if os.IsNotExist(err) {
+		b, err = bindings.Default(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Validate(knownInput); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return b, nil
+}
+
+// controllersFor lets both players share the keyboard, touchscreen and
+// mouse, in the configured pointer mode, and gives each of them the gamepad
+// assigned to their side. As before rebinding was possible, each player's
+// movement keys also start the game. The top and bottom paddles of a four
+// player match have keys and gamepads, steered with the stick's horizontal
+// axis, and the spinner, but no mouse or touch since those split the arena
+// into left and right halves.
+func (g *Game) controllersFor(b bindings.Bindings) [sim.MaxSides]control.Controller {
+	start, pause := keysFor(b[bindings.Start]), keysFor(b[bindings.Pause])
+	keyboard := func(up, down bindings.Action) *Keyboard {
+		k := &Keyboard{
+			Up:    keysFor(b[up]),
+			Down:  keysFor(b[down]),
+			Pause: pause,
+		}
+		k.Start = append(append(append([]ebiten.Key{}, k.Up...), k.Down...), start...)
+		return k
+	}
+	gamepad := func(side sim.Side, up, down bindings.Action, axis int) *Gamepad {
+		return &Gamepad{
+			Pads:     g.Gamepads,
+			Side:     side,
+			Axis:     axis,
+			Up:       gamepadButtonsFor(b[up]),
+			Down:     gamepadButtonsFor(b[down]),
+			Start:    gamepadButtonsFor(b[bindings.Start]),
+			Pause:    gamepadButtonsFor(b[bindings.Pause]),
+			DeadZone: g.DeadZone,
+		}
+	}
+	spinner := func() []control.Controller {
+		if g.Pointer == pointerSpinner {
+			return []control.Controller{g.spinner}
+		}
+		return nil
+	}
+	pointers := func() []control.Controller {
+		follow := g.Pointer == pointerFollow
+		return append([]control.Controller{
+			&Touch{View: &g.View, Follow: follow},
+			&Mouse{View: &g.View, Follow: follow},
+		}, spinner()...)
+	}
+	return [sim.MaxSides]control.Controller{
+		sim.Left: append(control.Multi{
+			keyboard(bindings.LeftUp, bindings.LeftDown),
+			gamepad(sim.Left, bindings.LeftUp, bindings.LeftDown, 1),
+		}, pointers()...),
+		sim.Right: append(control.Multi{
+			keyboard(bindings.RightUp, bindings.RightDown),
+			gamepad(sim.Right, bindings.RightUp, bindings.RightDown, 1),
+		}, pointers()...),
+		sim.Top: append(control.Multi{
+			keyboard(bindings.TopLeft, bindings.TopRight),
+			gamepad(sim.Top, bindings.TopLeft, bindings.TopRight, 0),
+		}, spinner()...),
+		sim.Bottom: append(control.Multi{
+			keyboard(bindings.BottomLeft, bindings.BottomRight),
+			gamepad(sim.Bottom, bindings.BottomLeft, bindings.BottomRight, 0),
+		}, spinner()...),
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
+
+	"github.com/fabianvf/pong-golang/pkg/control"
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// Which paddle, if any, the computer plays.
+const (
+	cpuOff    = ""
+	cpuLeft   = "left"
+	cpuRight  = "right"
+	cpuTop    = "top"
+	cpuBottom = "bottom"
+)
+
+// cpuChoices are in the order F2 cycles through them. Top and bottom are
+// only offered in four player matches.
+var cpuChoices = []string{cpuOff, cpuRight, cpuLeft, cpuTop, cpuBottom}
+
+func validCPU(name string) bool {
+	for _, c := range cpuChoices {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func cpuSide(name string) sim.Side {
+	switch name {
+	case cpuLeft:
+		return sim.Left
+	case cpuTop:
+		return sim.Top
+	case cpuBottom:
+		return sim.Bottom
+	}
+	return sim.Right
+}
+
+// withCPU hands the computer's paddle over to an AI.
+func (g *Game) withCPU(controllers [sim.MaxSides]control.Controller) [sim.MaxSides]control.Controller {
+	if g.CPU != cpuOff {
+		controllers[cpuSide(g.CPU)] = control.NewAI(g.Difficulty, g.Seed)
+	}
+	return controllers
+}
+
+// updateCPUMenu lets players pick an opponent from the start screen: F2
+// cycles which paddle the computer plays and F3 how well.
+func (g *Game) updateCPUMenu() {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyF2):
+		choices := cpuChoices
+		if g.State.Config.Sides() == 2 {
+			choices = choices[:3]
+		}
+		next := cpuOff
+		for i, c := range choices {
+			if c == g.CPU {
+				next = choices[(i+1)%len(choices)]
+				break
+			}
+		}
+		g.CPU = next
+	case inpututil.IsKeyJustPressed(ebiten.KeyF3):
+		for i, d := range control.Difficulties {
+			if d.Name == g.Difficulty.Name {
+				g.Difficulty = control.Difficulties[(i+1)%len(control.Difficulties)]
+				break
+			}
+		}
+	default:
+		return
+	}
+	g.Controllers = g.withCPU(g.controllersFor(g.Bindings))
+}
+
+func (g *Game) cpuMessage() string {
+	if g.CPU == cpuOff {
+		return "F2 CPU: OFF"
+	}
+	return fmt.Sprintf("F2 CPU: %s  F3 %s", strings.ToUpper(g.CPU), strings.ToUpper(g.Difficulty.Name))
+}
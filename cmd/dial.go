@@ -0,0 +1,19 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"net"
+
+	"github.com/fabianvf/pong-golang/pkg/server"
+)
+
+// dialServer connects to a pong server over plain TCP.
+func dialServer(addr string) (server.Conn, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return server.NewLineConn(conn), nil
+}
@@ -0,0 +1,92 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"syscall/js"
+
+	"github.com/fabianvf/pong-golang/pkg/server"
+)
+
+// dialServer connects to a pong server from the browser, which can only
+// open WebSockets.
+func dialServer(addr string) (server.Conn, error) {
+	if !strings.Contains(addr, "://") {
+		addr = "ws://" + addr
+	}
+	return dialWebSocket(addr)
+}
+
+// webSocket is a browser WebSocket. Its callbacks run on the JavaScript
+// event loop and must never block, so when messages come in faster than
+// they are read the newest are dropped.
+type webSocket struct {
+	ws       js.Value
+	messages chan []byte
+	closed   chan struct{}
+	once     sync.Once
+}
+
+func dialWebSocket(url string) (*webSocket, error) {
+	c := &webSocket{
+		ws:       js.Global().Get("WebSocket").New(url),
+		messages: make(chan []byte, 256),
+		closed:   make(chan struct{}),
+	}
+	opened := make(chan struct{})
+	c.on("open", func(js.Value) { close(opened) })
+	c.on("message", func(event js.Value) {
+		select {
+		case c.messages <- []byte(event.Get("data").String()):
+		default:
+		}
+	})
+	c.on("close", func(js.Value) { c.once.Do(func() { close(c.closed) }) })
+	c.on("error", func(js.Value) { c.once.Do(func() { close(c.closed) }) })
+
+	select {
+	case <-opened:
+		return c, nil
+	case <-c.closed:
+		return nil, errors.New("could not connect to " + url)
+	}
+}
+
+// on listens for event. The callbacks live as long as the page, as the
+// socket may still fire events after it is closed.
+func (c *webSocket) on(event string, f func(js.Value)) {
+	fn := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		f(args[0])
+		return nil
+	})
+	c.ws.Call("addEventListener", event, fn)
+}
+
+func (c *webSocket) ReadMessage() ([]byte, error) {
+	select {
+	case m := <-c.messages:
+		return m, nil
+	case <-c.closed:
+		return nil, io.EOF
+	}
+}
+
+func (c *webSocket) WriteMessage(p []byte) error {
+	select {
+	case <-c.closed:
+		return io.ErrClosedPipe
+	default:
+	}
+	c.ws.Call("send", string(p))
+	return nil
+}
+
+func (c *webSocket) Close() error {
+	c.ws.Call("close")
+	return nil
+}
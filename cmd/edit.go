@@ -0,0 +1,371 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
+	"github.com/hajimehoshi/ebiten/text"
+
+	"github.com/fabianvf/pong-golang/pkg/control"
+	"github.com/fabianvf/pong-golang/pkg/level"
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+var (
+	gridColor    = color.RGBA{0x00, 0x00, 0x00, 0x18}
+	marginColor  = color.RGBA{0x00, 0x00, 0x00, 0x20}
+	travelColor  = color.RGBA{0xdd, 0xdd, 0xdd, 0x60}
+	selectColor  = color.RGBA{0x2c, 0x5e, 0xd1, 0xff}
+	problemColor = color.RGBA{0xd0, 0x3a, 0x2f, 0xff}
+	spawnColor   = color.RGBA{0x2e, 0xa0, 0x43, 0xff}
+)
+
+var editHelp = []string{
+	"1-4      RECT CIRCLE BUMPER SPAWN",
+	"CLICK    PLACE OR SELECT",
+	"DRAG     MOVE",
+	"CORNER   RESIZE",
+	"SHIFT    DRAG SELECTED TO SET TRAVEL",
+	"RIGHT    DELETE",
+	"ESC      DESELECT",
+	"WHEEL    GOAL SIZE BY A WALL",
+	"T        CHANGE TYPE",
+	"[ ]      BOUNCE",
+	", .      TRAVEL TIME",
+	"G        GRID",
+	"ENTER    PLAY, ESC TO STOP",
+	"CTRL S   SAVE",
+}
+
+// levelEditor edits a level file with the mouse. Enter plays the level as
+// it stands and escape comes back to the editor.
+type levelEditor struct {
+	*Game
+	editor *level.Editor
+	path   string
+	config sim.Config
+	// options are what test matches are started with.
+	options Options
+	help    bool
+
+	play *Game
+}
+
+// runEdit implements "pong edit". Without a file a new level is started in
+// the user's level directory.
+func runEdit(args []string) error {
+	fs := flag.NewFlagSet("edit", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		return errors.New("usage: pong edit [file]")
+	}
+	if !validCPU(*cpu) {
+		return fmt.Errorf("-ai must be left, right, top or bottom, not %q", *cpu)
+	}
+	cpuLevel, ok := control.DifficultyByName(*difficulty)
+	if !ok {
+		return fmt.Errorf("unknown difficulty %q", *difficulty)
+	}
+	config, err := matchConfig()
+	if err != nil {
+		return err
+	}
+
+	var l *level.Level
+	path := fs.Arg(0)
+	if path == "" {
+		if path, err = newLevelPath(); err != nil {
+			return err
+		}
+		l = &level.Level{Name: strings.TrimSuffix(filepath.Base(path), ".json")}
+	} else if l, err = level.Open(path); os.IsNotExist(err) {
+		l = &level.Level{Name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+	} else if err != nil {
+		return err
+	}
+
+	b, bindingsPath := userBindings()
+	loadResources()
+	ebiten.SetMaxTPS(*tps)
+	ebiten.SetWindowResizable(true)
+	ebiten.SetWindowTitle("Pong level editor")
+
+	e := &levelEditor{
+		Game:   NewGame(Options{Config: config}),
+		editor: level.NewEditor(*l),
+		path:   path,
+		config: config,
+		options: Options{
+			Bindings:     b,
+			BindingsPath: bindingsPath,
+			DeadZone:     *deadZone,
+			Pointer:      *pointer,
+			CPU:          *cpu,
+			Difficulty:   cpuLevel,
+		},
+	}
+	return ebiten.RunGame(e)
+}
+
+// newLevelPath is the first unused level file name in the level directory.
+func newLevelPath() (string, error) {
+	dir, err := level.Dir()
+	if err != nil {
+		return "", err
+	}
+	for i := 1; ; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("level%d.json", i))
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path, nil
+		}
+	}
+}
+
+func (e *levelEditor) Update(screen *ebiten.Image) error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyF11) {
+		ebiten.SetFullscreen(!ebiten.IsFullscreen())
+	}
+	if e.play != nil {
+		if inpututil.IsKeyJustPressed(ebiten.KeyEscape) && e.play.rebind == nil {
+			e.play = nil
+			return nil
+		}
+		e.play.View = e.View
+		return e.play.Update(screen)
+	}
+	e.updateKeys()
+	e.updateMouse()
+	return nil
+}
+
+func (e *levelEditor) updateKeys() {
+	for i, tool := range level.Tools {
+		if inpututil.IsKeyJustPressed(ebiten.Key1 + ebiten.Key(i)) {
+			e.editor.Tool = tool
+		}
+	}
+	ctrl := ebiten.IsKeyPressed(ebiten.KeyControl)
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyS) && ctrl:
+		e.save()
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		e.startPlay()
+	case inpututil.IsKeyJustPressed(ebiten.KeyG):
+		e.editor.Snap = !e.editor.Snap
+	case inpututil.IsKeyJustPressed(ebiten.KeyH):
+		e.help = !e.help
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		e.editor.Selected = level.NoItem
+	case inpututil.IsKeyJustPressed(ebiten.KeyDelete), inpututil.IsKeyJustPressed(ebiten.KeyBackspace):
+		e.editor.Remove(e.editor.Selected)
+	case inpututil.IsKeyJustPressed(ebiten.KeyT):
+		e.editor.ChangeType()
+	case inpututil.IsKeyJustPressed(ebiten.KeyLeftBracket):
+		e.editor.Bounce(-0.1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyRightBracket):
+		e.editor.Bounce(0.1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyComma):
+		e.report(e.editor.Period(-0.5))
+	case inpututil.IsKeyJustPressed(ebiten.KeyPeriod):
+		e.report(e.editor.Period(0.5))
+	}
+}
+
+func (e *levelEditor) updateMouse() {
+	cursor := e.View.ToWorld(ebiten.CursorPosition())
+	if _, dy := ebiten.Wheel(); dy != 0 {
+		e.editor.ResizeGoal(cursor, dy)
+	}
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+		e.editor.Remove(e.editor.ItemAt(cursor))
+	}
+	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
+		e.editor.Release()
+	}
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		e.report(e.editor.Press(cursor, ebiten.IsKeyPressed(ebiten.KeyShift)))
+	}
+	if e.editor.Dragging() && ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		e.editor.DragTo(cursor)
+	}
+}
+
+// report shows why an edit couldn't be made, if it couldn't.
+func (e *levelEditor) report(err error) {
+	if err != nil {
+		e.notify(err.Error())
+	}
+}
+
+func (e *levelEditor) save() {
+	if err := level.Save(e.path, &e.editor.Level); err != nil {
+		e.notify("Can't save: " + firstProblem(err))
+		return
+	}
+	e.editor.Changed = false
+	e.notify("Saved " + filepath.Base(e.path))
+}
+
+// startPlay plays the level as it stands in a fresh match.
+func (e *levelEditor) startPlay() {
+	arena, err := e.editor.Level.Arena()
+	if err != nil {
+		e.notify("Can't play: " + firstProblem(err))
+		return
+	}
+	opts := e.options
+	opts.Config = e.config
+	opts.Config.Arena = arena
+	opts.Seed = matchSeed()
+	opts.Level = e.editor.Level.Name
+	e.play = NewGame(opts)
+	// F4 would otherwise swap in another level, which makes no sense
+	// while trying this one out.
+	e.play.levels = []level.Level{e.editor.Level}
+}
+
+// firstProblem is the first thing wrong in an error from level.Arena.
+func firstProblem(err error) string {
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) > 1 {
+		return strings.TrimSpace(lines[1])
+	}
+	return lines[0]
+}
+
+func (e *levelEditor) Draw(screen *ebiten.Image) {
+	if e.play != nil {
+		e.play.Draw(screen)
+		text.Draw(screen, "ESC EDIT", smallArcadeFont, smallFontSize, e.View.WindowHeight-smallFontSize, color.Black)
+		return
+	}
+	e.drawBackground(screen)
+	view := e.View.GeoM()
+	if e.editor.Snap {
+		for x := level.Grid; x < sim.ArenaWidth; x += level.Grid {
+			drawRect(screen, view, sim.Rect{X: x, W: sim.ArenaWidth / 1000, H: sim.ArenaHeight}, gridColor)
+		}
+		for y := level.Grid; y < sim.ArenaHeight; y += level.Grid {
+			drawRect(screen, view, sim.Rect{Y: y, W: sim.ArenaWidth, H: sim.ArenaWidth / 1000}, gridColor)
+		}
+	}
+	// Shade where obstacles and spawn points may not go.
+	const m = level.Margin
+	for _, r := range []sim.Rect{
+		{W: sim.ArenaWidth, H: m},
+		{Y: sim.ArenaHeight - m, W: sim.ArenaWidth, H: m},
+		{Y: m, W: m, H: sim.ArenaHeight - 2*m},
+		{X: sim.ArenaWidth - m, Y: m, W: m, H: sim.ArenaHeight - 2*m},
+	} {
+		drawRect(screen, view, r, marginColor)
+	}
+
+	arena, err := e.editor.Level.Arena()
+	for side := sim.Side(0); side < sim.MaxSides; side++ {
+		drawPosts(screen, view, &arena, side, e.config.PaddleWidth)
+	}
+	e.drawObstacles(screen, view)
+	for i, p := range e.editor.Level.Spawns {
+		if e.editor.Selected == (level.Item{Spawn: true, Index: i}) {
+			drawCircle(screen, view, p, level.SpawnSize*1.5, selectColor)
+		}
+		drawCircle(screen, view, p, level.SpawnSize, spawnColor)
+	}
+
+	e.drawStatus(screen, err)
+	e.drawNotice(screen)
+}
+
+// drawObstacles draws each obstacle, those with problems in red, with a
+// faint copy where moving ones turn back.
+func (e *levelEditor) drawObstacles(screen *ebiten.Image, view ebiten.GeoM) {
+	for i := range e.editor.Level.Obstacles {
+		o := &e.editor.Level.Obstacles[i]
+		built, err := o.Build()
+		if o.Move != (sim.Pair{}) {
+			travelled := built
+			travelled.Rect.X += o.Move.X
+			travelled.Rect.Y += o.Move.Y
+			travelled.Center.X += o.Move.X
+			travelled.Center.Y += o.Move.Y
+			drawObstacle(screen, view, travelled, travelColor)
+		}
+		c := obstacleColor
+		switch {
+		case err != nil:
+			c = problemColor
+		case built.Restitution > 1:
+			c = bumperColor
+		}
+		if e.editor.Selected == (level.Item{Index: i}) {
+			outline := built
+			grow := sim.ArenaWidth / 200
+			outline.Rect = sim.Rect{X: built.Rect.X - grow, Y: built.Rect.Y - grow, W: built.Rect.W + 2*grow, H: built.Rect.H + 2*grow}
+			outline.Radius += grow
+			drawObstacle(screen, view, outline, selectColor)
+			drawObstacle(screen, view, built, c)
+			h := level.Handle(o)
+			drawRect(screen, view, sim.Rect{X: h.X - level.HandleSize/2, Y: h.Y - level.HandleSize/2, W: level.HandleSize, H: level.HandleSize}, selectColor)
+			continue
+		}
+		drawObstacle(screen, view, built, c)
+	}
+}
+
+// drawStatus shows the help or the current tool and the selected
+// obstacle's settings at the top, and the level's first problem at the
+// bottom.
+func (e *levelEditor) drawStatus(screen *ebiten.Image, problem error) {
+	const lineHeight = smallFontSize * 3 / 2
+	x, y := smallFontSize, smallFontSize*2
+	if e.help {
+		for _, line := range editHelp {
+			text.Draw(screen, line, smallArcadeFont, x, y, color.Black)
+			y += lineHeight
+		}
+		return
+	}
+
+	name := e.editor.Level.Name
+	if e.editor.Changed {
+		name += "*"
+	}
+	grid := "OFF"
+	if e.editor.Snap {
+		grid = "ON"
+	}
+	lines := []string{
+		fmt.Sprintf("%s  TOOL %s  GRID %s  H HELP", name, e.editor.Tool, grid),
+	}
+	if o := e.editor.Obstacle(); o != nil {
+		size := fmt.Sprintf("%s %g X %g", o.Type, o.W, o.H)
+		if o.Type != level.Rect {
+			size = fmt.Sprintf("%s R %g", o.Type, o.Radius)
+		}
+		built, _ := o.Build()
+		bounce := built.Restitution
+		if bounce == 0 {
+			bounce = 1
+		}
+		line := fmt.Sprintf("%s  BOUNCE %.1f", size, bounce)
+		if o.Period > 0 {
+			line += fmt.Sprintf("  TRAVEL %g,%g IN %gS", o.Move.X, o.Move.Y, o.Period)
+		}
+		lines = append(lines, line)
+	}
+	for _, line := range lines {
+		text.Draw(screen, strings.ToUpper(line), smallArcadeFont, x, y, color.Black)
+		y += lineHeight
+	}
+	if problem != nil {
+		line := strings.ToUpper(firstProblem(problem))
+		text.Draw(screen, line, smallArcadeFont, x, e.View.WindowHeight-smallFontSize*3, problemColor)
+	}
+}
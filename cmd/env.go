@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fabianvf/pong-golang/pkg/control"
+	"github.com/fabianvf/pong-golang/pkg/env"
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// runEnv implements "pong env": a headless game driven by an agent over
+// line-delimited JSON on stdin and stdout, or on a TCP socket.
+func runEnv(args []string) error {
+	fs := flag.NewFlagSet("env", flag.ExitOnError)
+	side := fs.String("side", "left", "paddle controlled by the agent: left or right")
+	opponent := fs.String("opponent", control.Normal.Name, "computer difficulty for the other paddle, or none")
+	frameSkip := fs.Int("frame-skip", 4, "simulation steps per agent action")
+	points := fs.Int("points", 1, "points per episode")
+	listen := fs.String("listen", "", "serve on this TCP address instead of stdin and stdout")
+	fs.Parse(args)
+
+	config := sim.DefaultConfig()
+	// Agents gain nothing from waiting for the ball to be served.
+	config.Serve.Countdown = 0
+	opts := env.Options{
+		Config:    config,
+		FrameSkip: *frameSkip,
+		Points:    *points,
+		Seed:      *seed,
+	}
+	switch *side {
+	case "left":
+		opts.Side = sim.Left
+	case "right":
+		opts.Side = sim.Right
+	default:
+		return fmt.Errorf("-side must be left or right, not %q", *side)
+	}
+	if *opponent != "none" {
+		d, ok := control.DifficultyByName(*opponent)
+		if !ok {
+			return fmt.Errorf("unknown difficulty %q", *opponent)
+		}
+		opts.Opponent = func(seed int64) control.Controller {
+			return control.NewAI(d, seed)
+		}
+	}
+
+	if *listen != "" {
+		return env.ListenAndServe(*listen, func() *env.Env { return env.New(opts) })
+	}
+	return env.New(opts).Serve(os.Stdin, os.Stdout)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+const (
+	gamepadButtonPrefix = "GamepadButton"
+	noGamepad           = -1
+)
+
+// gamepadButtonByName parses names like "GamepadButton3", as used in the
+// bindings file.
+func gamepadButtonByName(name string) (ebiten.GamepadButton, bool) {
+	if !strings.HasPrefix(name, gamepadButtonPrefix) {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(name, gamepadButtonPrefix))
+	if err != nil || n < 0 || n > int(ebiten.GamepadButtonMax) {
+		return 0, false
+	}
+	return ebiten.GamepadButton(n), true
+}
+
+func gamepadButtonName(b ebiten.GamepadButton) string {
+	return gamepadButtonPrefix + strconv.Itoa(int(b))
+}
+
+func gamepadButtonsFor(names []string) []ebiten.GamepadButton {
+	var buttons []ebiten.GamepadButton
+	for _, name := range names {
+		if b, ok := gamepadButtonByName(name); ok {
+			buttons = append(buttons, b)
+		}
+	}
+	return buttons
+}
+
+// Gamepads hands the first connected gamepads to the left, right, top and
+// bottom paddles in that order, and keeps doing so as pads are plugged in
+// and out. Online, where this machine plays a single side, Share gives
+// every pad to that side instead.
+type Gamepads struct {
+	Assigned [sim.MaxSides]int
+
+	connected []int
+	shared    bool
+	local     sim.Side
+}
+
+func NewGamepads() *Gamepads {
+	g := &Gamepads{}
+	for side := range g.Assigned {
+		g.Assigned[side] = noGamepad
+	}
+	g.connected = ebiten.GamepadIDs()
+	for _, id := range g.connected {
+		g.assign(id)
+	}
+	return g
+}
+
+func (g *Gamepads) assign(id int) {
+	for _, assigned := range g.Assigned {
+		if assigned == id {
+			return
+		}
+	}
+	for side, assigned := range g.Assigned {
+		if assigned == noGamepad {
+			g.Assigned[side] = id
+			return
+		}
+	}
+}
+
+// Update picks up pads connected or disconnected since the last tick. A pad
+// that was left over when every side had one takes over a freed side.
+func (g *Gamepads) Update() {
+	for side, id := range g.Assigned {
+		if id != noGamepad && inpututil.IsGamepadJustDisconnected(id) {
+			g.Assigned[side] = noGamepad
+		}
+	}
+	g.connected = ebiten.GamepadIDs()
+	for _, id := range g.connected {
+		g.assign(id)
+	}
+}
+
+// Share gives every connected pad to side.
+func (g *Gamepads) Share(side sim.Side) {
+	g.shared, g.local = true, side
+}
+
+// For is the pads that drive side.
+func (g *Gamepads) For(side sim.Side) []int {
+	if g.shared {
+		if side == g.local {
+			return g.connected
+		}
+		return nil
+	}
+	if id := g.Assigned[side]; id != noGamepad {
+		return []int{id}
+	}
+	return nil
+}
+
+// Gamepad drives a paddle from the pads Pads gives Side. The stick
+// moves the paddle at a speed proportional to how far it is pushed.
+type Gamepad struct {
+	Pads  *Gamepads
+	Side  sim.Side
+	Axis  int
+	Up    []ebiten.GamepadButton
+	Down  []ebiten.GamepadButton
+	Start []ebiten.GamepadButton
+	Pause []ebiten.GamepadButton
+	// DeadZone is how far from centre, between 0 and 1, the stick must be
+	// pushed before the paddle moves. Past it the range is rescaled so the
+	// paddle still reaches full speed.
+	DeadZone float64
+}
+
+func (g *Gamepad) Intent(s *sim.State, side sim.Side) sim.Intent {
+	var in sim.Intent
+	for _, id := range g.Pads.For(g.Side) {
+		pad := g.read(id)
+		if math.Abs(pad.Axis) > math.Abs(in.Axis) {
+			in.Axis = pad.Axis
+		}
+		in.Buttons |= pad.Buttons
+	}
+	return in
+}
+
+func (g *Gamepad) read(id int) sim.Intent {
+	var in sim.Intent
+	// Some pads, like those with only a d-pad, lack the axis but still
+	// have buttons.
+	if g.Axis < ebiten.GamepadAxisNum(id) {
+		in.Axis = applyDeadZone(ebiten.GamepadAxis(id, g.Axis), g.DeadZone)
+	}
+	for _, b := range g.Up {
+		if ebiten.IsGamepadButtonPressed(id, b) {
+			in.Axis = -1
+		}
+	}
+	for _, b := range g.Down {
+		if ebiten.IsGamepadButtonPressed(id, b) {
+			in.Axis = 1
+		}
+	}
+	for _, b := range g.Start {
+		if ebiten.IsGamepadButtonPressed(id, b) {
+			in.Buttons |= sim.ButtonStart
+		}
+	}
+	for _, b := range g.Pause {
+		if ebiten.IsGamepadButtonPressed(id, b) {
+			in.Buttons |= sim.ButtonPause
+		}
+	}
+	return in
+}
+
+func applyDeadZone(v, deadZone float64) float64 {
+	if deadZone >= 1 || math.Abs(v) <= deadZone {
+		return 0
+	}
+	scaled := (math.Abs(v) - deadZone) / (1 - deadZone)
+	return math.Copysign(math.Min(scaled, 1), v)
+}
+
+// justPressedGamepadButton returns a button just pressed on any pad.
+func justPressedGamepadButton() (ebiten.GamepadButton, bool) {
+	for _, id := range ebiten.GamepadIDs() {
+		for b := ebiten.GamepadButton(0); b <= ebiten.GamepadButtonMax; b++ {
+			if inpututil.IsGamepadButtonJustPressed(id, b) {
+				return b, true
+			}
+		}
+	}
+	return 0, false
+}
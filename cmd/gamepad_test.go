@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+func TestGamepadsFor(t *testing.T) {
+	local := &Gamepads{Assigned: [sim.MaxSides]int{3, noGamepad, noGamepad, noGamepad}, connected: []int{3}}
+	if got := local.For(sim.Left); !reflect.DeepEqual(got, []int{3}) {
+		t.Errorf("left has pads %v, want the first one", got)
+	}
+	if got := local.For(sim.Right); got != nil {
+		t.Errorf("right has pads %v with one plugged in", got)
+	}
+
+	// Joining a match online puts this machine on the right, and its one
+	// pad has to drive that paddle rather than the remote player's.
+	joiner := &Gamepads{Assigned: local.Assigned, connected: []int{3, 5}}
+	joiner.Share(sim.Right)
+	if got := joiner.For(sim.Right); !reflect.DeepEqual(got, []int{3, 5}) {
+		t.Errorf("joiner's side has pads %v, want every one", got)
+	}
+	if got := joiner.For(sim.Left); got != nil {
+		t.Errorf("remote side has pads %v", got)
+	}
+}
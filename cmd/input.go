@@ -0,0 +1,200 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// Keyboard drives a paddle from held keys.
+type Keyboard struct {
+	Up    []ebiten.Key
+	Down  []ebiten.Key
+	Start []ebiten.Key
+	Pause []ebiten.Key
+}
+
+func anyKeyPressed(keys []ebiten.Key) bool {
+	for _, key := range keys {
+		if ebiten.IsKeyPressed(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *Keyboard) Intent(s *sim.State, side sim.Side) sim.Intent {
+	var in sim.Intent
+	if anyKeyPressed(k.Up) {
+		in.Axis--
+	}
+	if anyKeyPressed(k.Down) {
+		in.Axis++
+	}
+	if anyKeyPressed(k.Start) {
+		in.Buttons |= sim.ButtonStart
+	}
+	if anyKeyPressed(k.Pause) {
+		in.Buttons |= sim.ButtonPause
+	}
+	return in
+}
+
+// onSide reports whether a point in the arena is on the given paddle's half.
+func onSide(p sim.Pair, side sim.Side) bool {
+	if side == sim.Left {
+		return p.X < sim.ArenaWidth/2
+	}
+	return p.X > sim.ArenaWidth/2
+}
+
+// towards moves a paddle at full speed towards y.
+func towards(paddle *sim.Paddle, y float64) float64 {
+	switch c := paddle.Center().Y; {
+	case y < c:
+		return -1
+	case y > c:
+		return 1
+	}
+	return 0
+}
+
+// Pointer modes decide how the mouse and touchscreen move a paddle.
+const (
+	// pointerHold moves the paddle towards the pointer at normal speed while
+	// a finger or the mouse button is down.
+	pointerHold = "hold"
+	// pointerFollow makes the paddle track the pointer's height directly, up
+	// to the configured tracking speed.
+	pointerFollow = "follow"
+	// pointerSpinner turns the mouse wheel into a spinner knob that moves
+	// the paddle by a fixed distance per notch.
+	pointerSpinner = "spinner"
+)
+
+// Touch drives a paddle with any finger on its half of the arena. Any touch
+// on that half also counts as pressing start.
+type Touch struct {
+	View   *View
+	Follow bool
+}
+
+func (t *Touch) Intent(s *sim.State, side sim.Side) sim.Intent {
+	var in sim.Intent
+	for _, touchID := range ebiten.TouchIDs() {
+		touch := t.View.ToWorld(ebiten.TouchPosition(touchID))
+		if !onSide(touch, side) {
+			continue
+		}
+		if t.Follow {
+			in.Track, in.Target = true, touch.Y
+		} else {
+			in.Axis = towards(s.Paddle(side), touch.Y)
+		}
+		in.Buttons |= sim.ButtonStart
+	}
+	return in
+}
+
+// Mouse drives the paddle on whichever half of the arena the cursor is in.
+// When holding, the paddle moves towards the cursor only while the left
+// button is down, the same way a touch would; when following, it tracks the
+// cursor all the time. The left button also starts the game.
+type Mouse struct {
+	View   *View
+	Follow bool
+}
+
+func (m *Mouse) Intent(s *sim.State, side sim.Side) sim.Intent {
+	var in sim.Intent
+	cursor := m.View.ToWorld(ebiten.CursorPosition())
+	if !onSide(cursor, side) {
+		return in
+	}
+	pressed := ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft)
+	if pressed {
+		in.Buttons |= sim.ButtonStart
+	}
+	switch {
+	case m.Follow:
+		in.Track, in.Target = true, cursor.Y
+	case pressed:
+		in.Axis = towards(s.Paddle(side), cursor.Y)
+	}
+	return in
+}
+
+// Spinner drives the paddle on the cursor's half of the arena with the
+// mouse wheel, like the rotary knobs on the original arcade cabinet: each
+// notch moves the paddle a fixed distance, as fast as tracking allows. In a
+// four player match the wheel moves the paddle whose wall the cursor is
+// nearest.
+type Spinner struct {
+	View *View
+	// Sensitivity is how far, in arena units, one wheel notch moves the
+	// paddle.
+	Sensitivity float64
+
+	sides []spinnerSide
+}
+
+// spinnerSide is the wheel movement not yet used by one side, and where
+// that side's paddle is headed.
+type spinnerSide struct {
+	pending  float64
+	target   float64
+	spinning bool
+}
+
+func NewSpinner(view *View, sensitivity float64, players int) *Spinner {
+	return &Spinner{View: view, Sensitivity: sensitivity, sides: make([]spinnerSide, players)}
+}
+
+// Poll collects the wheel movement since the last frame for the side the
+// cursor is on. It must be called once per frame rather than per step, or
+// one flick would count twice.
+func (sp *Spinner) Poll() {
+	_, dy := ebiten.Wheel()
+	if dy != 0 {
+		sp.sides[sp.sideAt(sp.View.ToWorld(ebiten.CursorPosition()))].pending += dy
+	}
+}
+
+// sideAt is the side whose wall p is nearest, which for two players is
+// the half of the arena it is in.
+func (sp *Spinner) sideAt(p sim.Pair) sim.Side {
+	nearest := sim.Left
+	for side := sim.Side(1); int(side) < len(sp.sides); side++ {
+		if sim.WallDistance(side, p) < sim.WallDistance(nearest, p) {
+			nearest = side
+		}
+	}
+	return nearest
+}
+
+func (sp *Spinner) Intent(s *sim.State, side sim.Side) sim.Intent {
+	if int(side) >= len(sp.sides) {
+		return sim.Intent{}
+	}
+	state := &sp.sides[side]
+	cursor := sp.View.ToWorld(ebiten.CursorPosition())
+	if s.Mode != sim.ModePlay || sp.sideAt(cursor) != side {
+		state.spinning, state.pending = false, 0
+		return sim.Intent{}
+	}
+	center, limit := s.Paddle(side).Center().Y, sim.ArenaHeight
+	if side.Horizontal() {
+		center, limit = s.Paddle(side).Center().X, sim.ArenaWidth
+	}
+	if !state.spinning {
+		state.spinning = true
+		state.target = center
+	}
+	// Scrolling up moves the paddle up, towards smaller Y, or left.
+	target := state.target - state.pending*sp.Sensitivity
+	state.target = math.Max(0, math.Min(limit, target))
+	state.pending = 0
+	return sim.Intent{Track: true, Target: state.target}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"log"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
+
+	"github.com/fabianvf/pong-golang/pkg/level"
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+var (
+	wallColor     = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	obstacleColor = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+	bumperColor   = color.RGBA{0xf0, 0x8c, 0x1a, 0xff}
+)
+
+// newCircleImage is a white disc size pixels across, for drawing round
+// obstacles.
+func newCircleImage(size int) (*ebiten.Image, error) {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	r := float64(size) / 2
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float64(x)+0.5-r, float64(y)+0.5-r
+			if dx*dx+dy*dy <= r*r {
+				img.Set(x, y, color.White)
+			}
+		}
+	}
+	return ebiten.NewImageFromImage(img, ebiten.FilterLinear)
+}
+
+// matchLevel is the level picked with -level, if any.
+func matchLevel() (*level.Level, error) {
+	if *levelName == "" {
+		return &level.Builtin[0], nil
+	}
+	return level.Find(*levelName)
+}
+
+// updateLevelMenu lets players pick a level with F4 before a match starts.
+func (g *Game) updateLevelMenu() {
+	if !g.pickLevel() || !inpututil.IsKeyJustPressed(ebiten.KeyF4) {
+		return
+	}
+	if g.levels == nil {
+		var err error
+		if g.levels, err = level.List(); err != nil {
+			log.Printf("loading levels: %v", err)
+		}
+	}
+	next := 0
+	for i, l := range g.levels {
+		if l.Name == g.level {
+			next = (i + 1) % len(g.levels)
+			break
+		}
+	}
+	l := g.levels[next]
+	arena, err := l.Arena()
+	if err != nil {
+		log.Print(err)
+		g.notify("Could not load " + l.Name)
+		return
+	}
+	g.level = l.Name
+	g.State.Config.Arena = arena
+	g.State.Reset()
+	if g.recorder != nil {
+		g.recorder.Jump(g.State)
+	}
+}
+
+// pickLevel reports whether the level can be changed: only on a local
+// match that hasn't started.
+func (g *Game) pickLevel() bool {
+	return !g.online && !g.watching && g.State.Mode == sim.ModeWait && g.State.Stats.Ticks == 0
+}
+
+func (g *Game) levelMessage() string {
+	return "F4 Level: " + strings.ToUpper(g.level)
+}
+
+// drawArena draws the obstacles where they are now and the solid stretches
+// of wall either side of narrowed goals.
+func (g *Game) drawArena(screen *ebiten.Image, view ebiten.GeoM) {
+	s := &g.State
+	for side := sim.Side(0); side < sim.MaxSides; side++ {
+		if s.InPlay(side) {
+			drawPosts(screen, view, &s.Config.Arena, side, s.Config.PaddleWidth)
+		}
+	}
+	for _, o := range s.Config.Arena.Obstacles {
+		c := obstacleColor
+		if o.Restitution > 1 {
+			c = bumperColor
+		}
+		drawObstacle(screen, view, o.At(s.Stats.Ticks), c)
+	}
+}
+
+// drawPosts draws the wall either side of side's goal, if it is narrowed.
+func drawPosts(screen *ebiten.Image, view ebiten.GeoM, a *sim.Arena, side sim.Side, thickness float64) {
+	lo, hi := a.Mouth(side)
+	if lo == 0 {
+		return
+	}
+	length := sim.ArenaHeight
+	if side.Horizontal() {
+		length = sim.ArenaWidth
+	}
+	for _, span := range [2][2]float64{{0, lo}, {hi, length}} {
+		rect := sim.Rect{Y: span[0], W: thickness, H: span[1] - span[0]}
+		if side == sim.Right {
+			rect.X = sim.ArenaWidth - thickness
+		}
+		if side.Horizontal() {
+			rect = rect.Transposed()
+			if side == sim.Bottom {
+				rect.Y = sim.ArenaHeight - thickness
+			}
+		}
+		drawRect(screen, view, rect, wallColor)
+	}
+}
+
+func drawObstacle(screen *ebiten.Image, view ebiten.GeoM, o sim.Obstacle, c color.RGBA) {
+	switch o.Shape {
+	case sim.ShapeRect:
+		drawRect(screen, view, o.Rect, c)
+	case sim.ShapeCircle:
+		drawCircle(screen, view, o.Center, o.Radius, c)
+	}
+}
+
+// drawRect fills rect, given in arena units, with c.
+func drawRect(screen *ebiten.Image, view ebiten.GeoM, rect sim.Rect, c color.RGBA) {
+	paddleImage.Fill(color.White)
+	opts := ebiten.DrawImageOptions{}
+	opts.ColorM.Scale(float64(c.R)/0xff, float64(c.G)/0xff, float64(c.B)/0xff, float64(c.A)/0xff)
+	opts.GeoM.Scale(rect.W, rect.H)
+	opts.GeoM.Translate(rect.X, rect.Y)
+	opts.GeoM.Concat(view)
+	screen.DrawImage(paddleImage, &opts)
+}
+
+func drawCircle(screen *ebiten.Image, view ebiten.GeoM, center sim.Pair, radius float64, c color.RGBA) {
+	w, _ := circleImage.Size()
+	opts := ebiten.DrawImageOptions{}
+	opts.ColorM.Scale(float64(c.R)/0xff, float64(c.G)/0xff, float64(c.B)/0xff, float64(c.A)/0xff)
+	opts.GeoM.Scale(2*radius/float64(w), 2*radius/float64(w))
+	opts.GeoM.Translate(center.X-radius, center.Y-radius)
+	opts.GeoM.Concat(view)
+	screen.DrawImage(circleImage, &opts)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/fabianvf/pong-golang/pkg/lobby"
+	"github.com/fabianvf/pong-golang/pkg/netplay"
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// runLobby implements "pong lobby", the service players find each other
+// through.
+func runLobby(args []string) error {
+	fs := flag.NewFlagSet("lobby", flag.ExitOnError)
+	listen := fs.String("listen", ":7790", "TCP address to accept players on")
+	ratingsFile := fs.String("ratings", "", "file to keep player ratings in between runs")
+	fs.Parse(args)
+
+	ratings := lobby.Ratings{}
+	if *ratingsFile != "" {
+		var err error
+		if ratings, err = lobby.LoadRatings(*ratingsFile); err != nil {
+			return err
+		}
+	}
+	l := lobby.New(ratings)
+	l.RatingsPath = *ratingsFile
+	return l.ListenAndServe(*listen)
+}
+
+// runFind implements "pong find": it lists the rooms in a lobby, or opens,
+// joins or quick matches into one, and once both players are ready plays
+// the match and reports the result.
+func runFind(args []string) error {
+	fs := flag.NewFlagSet("find", flag.ExitOnError)
+	name := fs.String("name", os.Getenv("USER"), "name other players see")
+	port := fs.Int("port", 7777, "UDP port to host matches on")
+	create := fs.String("create", "", "open a room with this name")
+	join := fs.String("join", "", "join the room with this name")
+	password := fs.String("password", "", "password of the room to open or join")
+	quick := fs.Bool("quick", false, "play the first free player with a similar rating")
+	delay := fs.Int("delay", 2, "ticks local input is held back for when hosting")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: pong find [-create room | -join room | -quick] <lobby host:port>")
+	}
+
+	c, err := lobby.Dial(fs.Arg(0), *name, *port)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	switch {
+	case *create != "":
+		err = c.Create(*create, *password)
+	case *join != "":
+		err = c.Join(*join, *password)
+	case *quick:
+		err = c.Quick()
+	default:
+		rooms, err := c.List()
+		if err != nil {
+			return err
+		}
+		if len(rooms) == 0 {
+			fmt.Println("No open rooms")
+		}
+		for _, r := range rooms {
+			fmt.Println(r)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Your rating is %.0f. Press Enter when you're ready to play.\n", c.Rating)
+	go func() {
+		bufio.NewReader(os.Stdin).ReadString('\n')
+		c.Ready(true)
+	}()
+	for {
+		m, err := c.Next()
+		if err != nil {
+			return err
+		}
+		switch m.Type {
+		case lobby.MsgQueued:
+			fmt.Println("Looking for an opponent...")
+		case lobby.MsgRoom:
+			fmt.Println(m.Room)
+		case lobby.MsgError:
+			return errors.New(m.Error)
+		case lobby.MsgStart:
+			return playFound(c, m.Start, *delay)
+		}
+	}
+}
+
+// playFound connects to the opponent the lobby found, plays the match and
+// tells the lobby who won.
+func playFound(c *lobby.Client, start *lobby.Start, delay int) error {
+	fmt.Printf("Playing %s\n", start.Opponent)
+	var s *netplay.Session
+	if start.Host {
+		setup, err := hostSetup(delay)
+		if err != nil {
+			return err
+		}
+		t, err := netplay.Listen(net.JoinHostPort("", strconv.Itoa(start.Port)))
+		if err != nil {
+			return err
+		}
+		// The opponent is on their way, so don't wait for ever if they
+		// never turn up.
+		timer := time.AfterFunc(joinTimeout, func() { t.Close() })
+		s, err = netplay.Host(t, setup)
+		if !timer.Stop() {
+			return fmt.Errorf("%s never connected", start.Opponent)
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		t, err := netplay.Dial(start.Addr)
+		if err != nil {
+			return err
+		}
+		if s, err = netplay.Join(t, joinTimeout); err != nil {
+			return err
+		}
+	}
+
+	final, err := playOnline(s)
+	if err != nil {
+		return err
+	}
+	// A match left before it was over goes to whoever was ahead.
+	won := final.Winner == s.Local
+	if final.Mode != sim.ModeOver {
+		me, them := s.Local, 1-s.Local
+		won = final.Sets[me] > final.Sets[them] ||
+			final.Sets[me] == final.Sets[them] && final.Score[me] > final.Score[them]
+	}
+	if err := c.Report(won); err != nil {
+		return err
+	}
+	fmt.Printf("Your rating is now %.0f\n", c.Rating)
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
+	"github.com/hajimehoshi/ebiten/text"
+
+	"github.com/fabianvf/pong-golang/pkg/control"
+	"github.com/fabianvf/pong-golang/pkg/netplay"
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+const joinTimeout = 10 * time.Second
+
+// netGame plays a match against someone on another machine. Either set of
+// keys moves the local paddle.
+type netGame struct {
+	*Game
+	session *netplay.Session
+	err     error
+}
+
+func runHost(args []string) error {
+	fs := flag.NewFlagSet("host", flag.ExitOnError)
+	port := fs.Int("port", 7777, "UDP port to wait for the other player on")
+	delay := fs.Int("delay", 2, "ticks local input is held back for; more means fewer corrections on slow connections but laggier controls")
+	latency := fs.Duration("latency", 0, "add this much latency to outgoing packets, for testing")
+	fs.Parse(args)
+
+	setup, err := hostSetup(*delay)
+	if err != nil {
+		return err
+	}
+	t, err := netplay.Listen(net.JoinHostPort("", strconv.Itoa(*port)))
+	if err != nil {
+		return err
+	}
+	t = withLatency(t, *latency)
+
+	log.Printf("waiting for the other player on port %d", *port)
+	s, err := netplay.Host(t, setup)
+	if err != nil {
+		return err
+	}
+	_, err = playOnline(s)
+	return err
+}
+
+// hostSetup is the match a host offers, from the command line flags.
+func hostSetup(delay int) (netplay.Setup, error) {
+	config, err := oneOnOneConfig("online matches")
+	if err != nil {
+		return netplay.Setup{}, err
+	}
+	return netplay.Setup{Delay: delay, Seed: matchSeed(), Config: config}, nil
+}
+
+func runJoin(args []string) error {
+	fs := flag.NewFlagSet("join", flag.ExitOnError)
+	latency := fs.Duration("latency", 0, "add this much latency to outgoing packets, for testing")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: pong join <host:port>")
+	}
+
+	t, err := netplay.Dial(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	s, err := netplay.Join(withLatency(t, *latency), joinTimeout)
+	if err != nil {
+		return err
+	}
+	_, err = playOnline(s)
+	return err
+}
+
+func withLatency(t netplay.Transport, latency time.Duration) netplay.Transport {
+	if latency <= 0 {
+		return t
+	}
+	return netplay.NewLossy(t, latency, 0, 0, 0)
+}
+
+// playOnline plays s in a window and returns how the match stood when the
+// window was closed.
+func playOnline(s *netplay.Session) (sim.State, error) {
+	defer s.Close()
+	b, path := userBindings()
+	spectators, err := startSpectating()
+	if err != nil {
+		return sim.State{}, err
+	}
+
+	loadResources()
+	ebiten.SetMaxTPS(*tps)
+	ebiten.SetWindowResizable(true)
+	ebiten.SetWindowTitle("Pong online")
+
+	g := NewGame(Options{
+		Config:       s.Setup.Config,
+		Bindings:     b,
+		BindingsPath: path,
+		DeadZone:     *deadZone,
+		Pointer:      *pointer,
+		Seed:         s.Setup.Seed,
+		Spectators:   spectators,
+	})
+	g.online = true
+	g.Gamepads.Share(s.Local)
+	g.State = s.State()
+	err = ebiten.RunGame(&netGame{Game: g, session: s})
+	return g.State, err
+}
+
+func (n *netGame) Update(screen *ebiten.Image) error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyF11) {
+		ebiten.SetFullscreen(!ebiten.IsFullscreen())
+	}
+	n.Gamepads.Update()
+	n.spinner.Poll()
+	steps := n.clock.Advance(n.frameTime())
+	for i := 0; i < steps && n.err == nil; i++ {
+		local := control.Multi{n.Controllers[sim.Left], n.Controllers[sim.Right]}
+		events, err := n.session.Advance(local.Intent(&n.State, n.session.Local))
+		if err != nil {
+			log.Print(err)
+			n.err = err
+			break
+		}
+		n.State = n.session.State()
+		n.react(events)
+	}
+	return nil
+}
+
+func (n *netGame) Draw(screen *ebiten.Image) {
+	n.Game.Draw(screen)
+	var status string
+	switch {
+	case n.err != nil:
+		status = n.err.Error()
+	case n.session.Stalled():
+		status = "Waiting for the other player"
+	}
+	n.drawOnline(screen, n.session.Local, status)
+}
+
+// drawOnline tells the player which paddle is theirs between points, and
+// shows status, if any, at the bottom of the screen.
+func (g *Game) drawOnline(screen *ebiten.Image, side sim.Side, status string) {
+	if g.State.Mode == sim.ModeWait || g.State.Mode == sim.ModePause {
+		name := "left"
+		if side == sim.Right {
+			name = "right"
+		}
+		message := fmt.Sprintf("You are on the %s", name)
+		x, y := g.centerText(message, smallArcadeFont)
+		text.Draw(screen, message, smallArcadeFont, x, y+20+smallFontSize*2, color.Black)
+	}
+	if status != "" {
+		x, _ := g.centerText(status, smallArcadeFont)
+		text.Draw(screen, status, smallArcadeFont, x, g.View.WindowHeight-smallFontSize, color.Black)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"image"
@@ -9,7 +10,9 @@ import (
 	"os"
 	"os/signal"
 	"runtime/pprof"
+	"strings"
 	"syscall"
+	"time"
 
 	"image/color"
 	_ "image/jpeg"
@@ -27,16 +30,22 @@ import (
 	"github.com/hajimehoshi/ebiten/inpututil"
 	"github.com/hajimehoshi/ebiten/text"
 
-	"github.com/SolarLune/resolv/resolv"
-
+	"github.com/fabianvf/pong-golang/pkg/bindings"
+	"github.com/fabianvf/pong-golang/pkg/control"
 	"github.com/fabianvf/pong-golang/pkg/future"
+	"github.com/fabianvf/pong-golang/pkg/level"
+	"github.com/fabianvf/pong-golang/pkg/replay"
 	raudio "github.com/fabianvf/pong-golang/pkg/resources/audio"
 	rimage "github.com/fabianvf/pong-golang/pkg/resources/images"
+	"github.com/fabianvf/pong-golang/pkg/sim"
+	"github.com/fabianvf/pong-golang/pkg/snapshot"
+	"github.com/fabianvf/pong-golang/pkg/spectate"
 )
 
 var (
 	paddleImage      *ebiten.Image
 	ballImage        *ebiten.Image
+	circleImage      *ebiten.Image
 	backgroundImage  *ebiten.Image
 	arcadeFont       font.Face
 	smallArcadeFont  font.Face
@@ -45,20 +54,52 @@ var (
 	hitPlayer        *audio.Player
 	cpuprofile       = flag.String("cpuprofile", "", "write cpu profile to file")
 	memprofile       = flag.String("memprofile", "", "write memory profile to file")
+	bindingsFile     = flag.String("bindings", "", "key bindings file (default is pong/bindings.json in the user config directory)")
+	deadZone         = flag.Float64("deadzone", 0.15, "fraction of a gamepad stick's travel ignored around the centre")
+	pointer          = flag.String("pointer", pointerHold, "how the mouse and touchscreen move paddles: hold, follow or spinner")
+	trackSpeed       = flag.Float64("track-speed", 1.5, "top speed of a paddle following the pointer, relative to the keyboard speed")
+	cpu              = flag.String("ai", cpuOff, "let the computer play the left, right, top or bottom paddle")
+	difficulty       = flag.String("difficulty", control.Normal.Name, "computer player difficulty: easy, normal, hard or impossible")
+	seed             = flag.Int64("seed", 0, "random seed for the match (default is based on the time)")
+	record           = flag.String("record", "", "save a replay of the session to this file")
+	tps              = flag.Int("tps", ebiten.DefaultTPS, "ticks per second of the window loop; does not affect game speed")
+	load             = flag.String("load", "", "start from a snapshot file, such as one saved with F5")
+	spectatePort     = flag.Int("spectate-port", 0, "let spectators watch the match with pong spectate on this TCP port")
+	points           = flag.Int("points", 11, "points needed to win a set, or 0 to play forever")
+	winBy            = flag.Int("win-by", 2, "lead needed to win a set")
+	sets             = flag.Int("sets", 1, "play the best of this many sets")
+	players          = flag.Int("players", 2, "2, or 4 for paddles on every wall")
+	lives            = flag.Int("lives", 0, "goals a player can let in before they are out; 0 plays for points instead")
+	serveRule        = flag.String("serve", "alternate", "who serves after a point: alternate, loser or winner")
+	serveAngle       = flag.Float64("serve-angle", 45, "largest angle from the horizontal, in degrees, the ball is served at")
+	countdown        = flag.Float64("countdown", 1, "seconds the ball is held before it is served")
+	manualServe      = flag.Bool("manual-serve", false, "hold the ball on the server's paddle until they press start")
+	balls            = flag.Int("balls", 1, "most balls in play at once; extra ones come from -spawn-every and -spawn-hits")
+	spawnEvery       = flag.Float64("spawn-every", 0, "seconds between extra balls, or 0 for none")
+	spawnHits        = flag.Int("spawn-hits", 0, "add a ball every this many paddle hits, or 0 for none")
+	firstGoal        = flag.Bool("first-goal", false, "end the round at the first goal instead of when the last ball has gone")
+	powerUps         = flag.String("powerups", "", "comma separated power-ups to play with, or all: grow, shrink, speed, sticky, split, slow and invisible")
+	powerUpEvery     = flag.Float64("powerup-every", 8, "seconds between power-ups appearing")
+	powerUpTime      = flag.Float64("powerup-time", 6, "seconds a power-up's effect lasts")
+	levelName        = flag.String("level", "", "level to play: classic, pillars, bumpers, sweeper, narrow or a level file")
+	snapshotFile     = flag.String("snapshot", "", "file F5 saves to and F9 loads from; .json files are saved as JSON (default is pong/quicksave.snap in the user config directory)")
 )
 
 const (
-	leftPaddle    = "left"
-	rightPaddle   = "right"
-	gameModeWait  = 0
-	gameModePlay  = 1
-	gameModePause = 2
 	fontSize      = 32
 	smallFontSize = fontSize / 2
 	trailPolygons = 1000
+	// trailRate is the rate the trail's look was tuned at; it is used to keep
+	// its length independent of sim.TickRate.
+	trailRate = 60
+	// spinnerSensitivity is how far one mouse wheel notch moves a paddle.
+	spinnerSensitivity = sim.ArenaHeight / 20
 )
 
-func init() {
+// loadResources prepares the images, fonts and sounds used by the window.
+// Headless commands never call it, so they need neither a display nor an
+// audio device.
+func loadResources() {
 	var err error
 	img, _, err := image.Decode(bytes.NewReader(rimage.Background_png))
 	if err != nil {
@@ -77,6 +118,10 @@ func init() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	circleImage, err = newCircleImage(64)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	tt, err := truetype.Parse(fonts.ArcadeN_ttf)
 	if err != nil {
@@ -114,24 +159,21 @@ func init() {
 	}
 }
 
-type Pair struct {
-	X float64
-	Y float64
-}
-
 type TrailElement struct {
 	Radius   float64
-	Coord    Pair
-	Velocity Pair
+	Coord    sim.Pair
+	Velocity sim.Pair
 	Angle    float64
 	Speed    float64
 }
 
-func (t *TrailElement) Draw(screen *ebiten.Image, options *ebiten.DrawImageOptions) {
+func (t *TrailElement) Draw(screen *ebiten.Image, view ebiten.GeoM, options *ebiten.DrawImageOptions) {
 	options.GeoM.Reset()
 	options.GeoM.Scale(t.Radius, t.Radius+t.Speed)
+	options.GeoM.Translate(-t.Radius/2, 0)
 	options.GeoM.Rotate(t.Angle)
 	options.GeoM.Translate(t.Coord.X, t.Coord.Y)
+	options.GeoM.Concat(view)
 
 	screen.DrawImage(ballImage, options)
 }
@@ -146,404 +188,521 @@ type Trail struct {
 	currentAngle float64
 }
 
-func (t *Trail) Draw(screen *ebiten.Image, options *ebiten.DrawImageOptions) {
+func (t *Trail) Draw(screen *ebiten.Image, view ebiten.GeoM, options *ebiten.DrawImageOptions) {
 	for _, element := range t.elements {
-		element.Draw(screen, options)
+		element.Draw(screen, view, options)
 	}
 }
 
-func (t *Trail) UpdateAngle(velocity Pair) {
+func (t *Trail) UpdateAngle(velocity sim.Pair) {
 	t.currentAngle = math.Atan2(velocity.Y, velocity.X) - (3*math.Pi)/2
 }
 
-func (t *Trail) Add(b *Ball) {
+func (t *Trail) Add(b *sim.Ball) {
 	newElement := TrailElement{
-		Radius:   float64(b.Radius) * 0.5,
+		Radius:   b.Radius,
 		Coord:    b.Coord,
 		Velocity: b.Velocity,
 		Angle:    t.currentAngle,
-		Speed:    math.Sqrt(math.Pow(b.Velocity.Y, 2) + math.Pow(b.Velocity.X, 2)),
-	}
-	newElement.Coord.Y += newElement.Radius / 2
-	if newElement.Velocity.X < 0 {
-		newElement.Coord.Y += newElement.Radius
+		Speed:    math.Sqrt(math.Pow(b.Velocity.Y, 2)+math.Pow(b.Velocity.X, 2)) / trailRate,
 	}
 	t.elements = append(
 		[]TrailElement{newElement},
 		t.elements[:len(t.elements)-1]...)
+	decay := math.Pow(0.9, trailRate*sim.Dt)
 	for i, _ := range t.elements {
-		t.elements[i].Radius = t.elements[i].Radius * 0.9
+		t.elements[i].Radius = t.elements[i].Radius * decay
 	}
 }
 
-func NewBall(radius int32, x, y, vy, vx, minV, maxV, speed float64) *Ball {
-	return &Ball{
-		Radius:         radius,
-		Coord:          Pair{X: x, Y: y},
-		Velocity:       Pair{X: vx, Y: vy},
-		VelocityBounds: Pair{X: minV, Y: maxV},
-		BaseSpeed:      speed,
-		boundingBox:    *resolv.NewCircle(int32(x), int32(y), int32(radius)),
-		trail:          NewTrail(),
+// NewTrails starts a trail for each ball, angled the way it is heading.
+func NewTrails(balls *[sim.MaxBalls]sim.Ball) [sim.MaxBalls]Trail {
+	var trails [sim.MaxBalls]Trail
+	for i := range trails {
+		trails[i] = NewTrail()
+		trails[i].UpdateAngle(balls[i].Velocity)
 	}
+	return trails
 }
 
-type Ball struct {
-	Radius         int32
-	Coord          Pair
-	Velocity       Pair
-	VelocityBounds Pair
-	BaseSpeed      float64
-	boundingBox    resolv.Circle
-	trail          Trail
+// drawBalls draws every ball in play with its trail. Invisible balls only
+// show in glimpses.
+func drawBalls(screen *ebiten.Image, view ebiten.GeoM, s *sim.State, trails *[sim.MaxBalls]Trail) {
+	for i := range s.Balls {
+		b := &s.Balls[i]
+		if b.Live && (!s.Hidden(b) || glimpse(s)) {
+			drawBall(screen, view, b, &trails[i])
+		}
+	}
 }
 
-func (b *Ball) Draw(screen *ebiten.Image) {
+func drawBall(screen *ebiten.Image, view ebiten.GeoM, b *sim.Ball, trail *Trail) {
 	ballImage.Fill(color.White)
 	ballOpts := ebiten.DrawImageOptions{}
-	ballOpts.GeoM.Scale(float64(b.Radius), float64(b.Radius))
-	ballOpts.GeoM.Translate(float64(b.Coord.X), float64(b.Coord.Y))
+	ballOpts.GeoM.Scale(2*b.Radius, 2*b.Radius)
+	ballOpts.GeoM.Translate(b.Coord.X-b.Radius, b.Coord.Y-b.Radius)
+	ballOpts.GeoM.Concat(view)
 	screen.DrawImage(ballImage, &ballOpts)
-	b.trail.Draw(screen, &ballOpts)
-}
-
-func (b *Ball) BoundingBox() *resolv.Circle {
-	b.boundingBox.X = int32(b.Coord.X)
-	b.boundingBox.Y = int32(b.Coord.Y)
-	b.boundingBox.Radius = b.Radius
-	return &b.boundingBox
-}
-
-func NewGame() *Game {
+	trail.Draw(screen, view, &ballOpts)
+}
+
+// Options are the settings a Game is started with.
+type Options struct {
+	Config       sim.Config
+	Bindings     bindings.Bindings
+	BindingsPath string
+	DeadZone     float64
+	Pointer      string
+	CPU          string
+	Difficulty   control.Difficulty
+	// Seed makes everything random in a match, such as the computer
+	// player's mistakes, repeatable.
+	Seed int64
+	// Record is a file to save a replay of the session to.
+	Record string
+	// Snapshot, if set, is the match to start from instead of a new one.
+	Snapshot     *snapshot.Snapshot
+	SnapshotPath string
+	// Spectators, if set, is sent every tick of the match.
+	Spectators *spectate.Publisher
+	// Level is the name of the level Config.Arena was built from.
+	Level string
+}
+
+func NewGame(opts Options) *Game {
 	backgroundPlayer.Play()
 	g := &Game{
-		GameMode:    gameModeWait,
-		Score:       Pair{X: 0, Y: 0},
-		LeftPaddle:  *resolv.NewRectangle(1, 8, 2, 4),
-		RightPaddle: *resolv.NewRectangle(15, 8, 2, 4),
-		Ball:        *NewBall(3, 8, 4, 0, 0, 0, 0, 0),
-	}
-	g.Reset()
+		State:        sim.NewState(opts.Config, opts.Seed),
+		Bindings:     opts.Bindings,
+		Gamepads:     NewGamepads(),
+		DeadZone:     opts.DeadZone,
+		Pointer:      opts.Pointer,
+		CPU:          opts.CPU,
+		Difficulty:   opts.Difficulty,
+		Seed:         opts.Seed,
+		bindingsPath: opts.BindingsPath,
+		recordPath:   opts.Record,
+		snapshotPath: opts.SnapshotPath,
+		spectators:   opts.Spectators,
+		level:        opts.Level,
+	}
+	if opts.Snapshot != nil {
+		g.State = opts.Snapshot.State
+		g.Seed = opts.Snapshot.Seed
+	}
+	g.trails = NewTrails(&g.State.Balls)
+	if g.recordPath != "" {
+		g.recorder = replay.NewRecorder(g.State, g.Seed)
+	}
+	g.spinner = NewSpinner(&g.View, spinnerSensitivity, g.State.Config.Sides())
+	g.Controllers = g.withCPU(g.controllersFor(g.Bindings))
 	return g
 }
 
+// Game adapts the headless simulation in pkg/sim to ebiten: it polls input,
+// steps the simulation once per tick and renders the result.
 type Game struct {
-	GameMode     int
-	Score        Pair
-	LeftPaddle   resolv.Rectangle
-	RightPaddle  resolv.Rectangle
-	Ball         Ball
-	WindowHeight int32
-	WindowWidth  int32
-	PaddleSpeed  int32
+	State       sim.State
+	View        View
+	Controllers [sim.MaxSides]control.Controller
+	Bindings    bindings.Bindings
+	Gamepads    *Gamepads
+	DeadZone    float64
+	Pointer     string
+	CPU         string
+	Difficulty  control.Difficulty
+	Seed        int64
+
+	bindingsPath string
+	rebind       *rebindScreen
+	spinner      *Spinner
+	recordPath   string
+	recorder     *replay.Recorder
+	snapshotPath string
+	quicksave    *snapshot.Snapshot
+	notice       string
+	noticeUntil  time.Time
+	spectators   *spectate.Publisher
+	// watching is set when the game is only being watched, as in a replay,
+	// so no prompts for the players are drawn.
+	watching bool
+	// online is set for matches over the network, where the menus that
+	// only make sense locally are hidden.
+	online bool
+	// names are the players' names, if known, and overPrompt replaces the
+	// rematch prompt on the game over screen.
+	names      [sim.MaxSides]string
+	overPrompt string
+	// level is the name of the level being played, and levels those F4
+	// picks from once it has been pressed.
+	level  string
+	levels []level.Level
+
+	clock  sim.Clock
+	trails [sim.MaxBalls]Trail
+
+	lastUpdate time.Time
+}
+
+func (g *Game) pollInputs() sim.Inputs {
+	var inputs sim.Inputs
+	for side, c := range g.Controllers {
+		inputs[side] = c.Intent(&g.State, sim.Side(side))
+	}
+	return inputs
 }
 
-func (g *Game) Reset() {
-	g.Ball = *NewBall(3, 8, 4, 0, 0, 0, 0, 0)
-	g.Ball.Coord.X = float64(g.WindowWidth) / 2
-	g.Ball.Coord.Y = float64(g.WindowHeight) / 2
-	g.Ball.Radius = int32(float64(g.WindowWidth) / 60)
-	g.Ball.BaseSpeed = float64(g.WindowWidth) / 100
-
-	g.Ball.Velocity.X = g.Ball.BaseSpeed
-	g.Ball.Velocity.Y = g.Ball.BaseSpeed
-
-	g.Ball.VelocityBounds.X = g.Ball.BaseSpeed
-	g.Ball.VelocityBounds.Y = g.Ball.BaseSpeed * 3
-
-	g.LeftPaddle.X = int32(g.WindowWidth / 16)
-	g.LeftPaddle.Y = int32(g.WindowHeight / 2)
-	g.LeftPaddle.W = int32(float64(g.WindowWidth) / 60)
-	g.LeftPaddle.H = int32(float64(g.WindowHeight) / 5)
-
-	g.RightPaddle.X = int32(g.WindowWidth - g.WindowWidth/16)
-	g.RightPaddle.Y = int32(g.WindowHeight / 2)
-	g.RightPaddle.W = int32(float64(g.WindowWidth) / 60)
-	g.RightPaddle.H = int32(float64(g.WindowHeight) / 5)
-	g.PaddleSpeed = int32(float64(g.WindowHeight) / 60)
-	g.Ball.trail.UpdateAngle(g.Ball.Velocity)
-}
-
-func Abs(x float64) float64 {
-	if x < 0 {
-		return -x
+func (g *Game) Update(screen *ebiten.Image) error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyF11) {
+		ebiten.SetFullscreen(!ebiten.IsFullscreen())
+	}
+	g.Gamepads.Update()
+	g.spinner.Poll()
+	if g.rebind != nil {
+		if closed, save := g.rebind.Update(); closed {
+			if save {
+				g.applyBindings(g.rebind.bindings)
+			}
+			g.rebind = nil
+		}
+		return nil
 	}
-	return x
-}
-
-func (g *Game) LeftPaddleUp() bool {
-	if g.LeftPaddle.Y < 0 {
-		return false
+	if g.State.Mode != sim.ModePlay && inpututil.IsKeyJustPressed(ebiten.KeyF1) {
+		g.rebind = newRebindScreen(g.Bindings)
+		return nil
 	}
-
-	if ebiten.IsKeyPressed(ebiten.KeyW) {
-		return true
+	if g.State.Mode == sim.ModeWait {
+		g.updateCPUMenu()
+		g.updateLevelMenu()
 	}
-
-	for _, touchID := range ebiten.TouchIDs() {
-		x, y := ebiten.TouchPosition(touchID)
-		if x < int(g.WindowWidth/2) {
-			if y < int(g.LeftPaddle.Y+g.LeftPaddle.H/2) {
-				return true
-			}
-		}
+	if !g.watching {
+		g.updateSnapshots()
 	}
-	return false
-}
-
-func (g *Game) LeftPaddleDown() bool {
-	if g.LeftPaddle.Y+g.LeftPaddle.H > g.WindowHeight {
-		return false
+	steps := g.clock.Advance(g.frameTime())
+	for i := 0; i < steps; i++ {
+		g.step(g.pollInputs())
 	}
+	return nil
+}
 
-	if ebiten.IsKeyPressed(ebiten.KeyS) {
-		return true
+// applyBindings switches the keyboard over to b and writes it back to the
+// bindings file, if there is one.
+func (g *Game) applyBindings(b bindings.Bindings) {
+	g.Bindings = b
+	g.Controllers = g.withCPU(g.controllersFor(b))
+	if g.bindingsPath == "" {
+		return
+	}
+	if err := b.Save(g.bindingsPath); err != nil {
+		log.Printf("saving bindings: %v", err)
+	}
+}
+
+// saveRecording writes everything recorded so far. It is called after every
+// point and when the window closes, so little is lost if the game crashes.
+func (g *Game) saveRecording() {
+	if g.recorder == nil {
+		return
+	}
+	if err := replay.Save(g.recordPath, g.recorder.Replay()); err != nil {
+		log.Printf("saving replay: %v", err)
+	}
+}
+
+// frameTime is the number of seconds one call to Update stands for. ebiten
+// calls Update exactly MaxTPS times a second, catching up after slow frames,
+// so wall-clock time is only needed when the tick rate is uncapped.
+func (g *Game) frameTime() float64 {
+	now := time.Now()
+	last := g.lastUpdate
+	g.lastUpdate = now
+	if tps := ebiten.MaxTPS(); tps > 0 {
+		return 1 / float64(tps)
+	}
+	if last.IsZero() {
+		return 0
+	}
+	return now.Sub(last).Seconds()
+}
+
+func (g *Game) step(inputs sim.Inputs) {
+	if g.recorder != nil {
+		g.recorder.Record(inputs)
+	}
+	var events []sim.Event
+	g.State, events = sim.Step(g.State, inputs)
+	g.react(events)
+}
+
+// react plays sounds and updates effects for what happened in the last step,
+// and passes the step on to any spectators.
+func (g *Game) react(events []sim.Event) {
+	if g.spectators != nil {
+		g.spectators.Publish(g.State, events)
+	}
+	for _, e := range events {
+		switch e.Kind {
+		case sim.EventStart:
+			g.trails = NewTrails(&g.State.Balls)
+		case sim.EventServe:
+			g.trails[0].UpdateAngle(g.State.Balls[0].Velocity)
+		case sim.EventSpawn:
+			g.trails[e.Ball] = NewTrail()
+			g.trails[e.Ball].UpdateAngle(g.State.Balls[e.Ball].Velocity)
+		case sim.EventPaddleHit:
+			hitPlayer.Rewind()
+			hitPlayer.Play()
+			g.trails[e.Ball].UpdateAngle(g.State.Balls[e.Ball].Velocity)
+		case sim.EventWallBounce:
+			g.trails[e.Ball].UpdateAngle(g.State.Balls[e.Ball].Velocity)
+		case sim.EventBallHit:
+			hitPlayer.Rewind()
+			hitPlayer.Play()
+			for i := range g.trails {
+				g.trails[i].UpdateAngle(g.State.Balls[i].Velocity)
+			}
+		case sim.EventCollect:
+			hitPlayer.Rewind()
+			hitPlayer.Play()
+			g.trails[e.Ball].UpdateAngle(g.State.Balls[e.Ball].Velocity)
+		case sim.EventRelease:
+			hitPlayer.Rewind()
+			hitPlayer.Play()
+			g.trails[e.Ball].UpdateAngle(g.State.Balls[e.Ball].Velocity)
+		case sim.EventGoal:
+			g.trails[e.Ball] = NewTrail()
+			g.saveRecording()
+		}
 	}
-
-	for _, touchID := range ebiten.TouchIDs() {
-		x, y := ebiten.TouchPosition(touchID)
-		if x < int(g.WindowWidth/2) {
-			if y > int(g.LeftPaddle.Y+g.LeftPaddle.H/2) {
-				return true
+	if g.State.Mode == sim.ModePlay {
+		for i := range g.State.Balls {
+			if g.State.Balls[i].Live {
+				g.trails[i].Add(&g.State.Balls[i])
 			}
 		}
 	}
-	return false
 }
 
-func (g *Game) RightPaddleUp() bool {
-	if g.RightPaddle.Y < 0 {
-		return false
-	}
+func (g *Game) centerText(content string, face font.Face) (int, int) {
+	centerX, centerY := g.View.WindowWidth/2, g.View.WindowHeight/2
 
-	if ebiten.IsKeyPressed(ebiten.KeyUp) {
-		return true
-	}
+	stringSize := future.MeasureString(content, face)
+	centerX -= stringSize.X / 2
+	centerY -= stringSize.Y / 2
+
+	return centerX, centerY
 
-	for _, touchID := range ebiten.TouchIDs() {
-		x, y := ebiten.TouchPosition(touchID)
-		if x > int(g.WindowWidth/2) {
-			if y < int(g.RightPaddle.Y+g.RightPaddle.H/2) {
-				return true
-			}
-		}
-	}
-	return false
 }
 
-func (g *Game) RightPaddleDown() bool {
-	if g.RightPaddle.Y+g.RightPaddle.H > g.WindowHeight {
-		return false
+func (g *Game) drawStart(screen *ebiten.Image) {
+	if g.watching {
+		return
 	}
-
-	if ebiten.IsKeyPressed(ebiten.KeyDown) {
-		return true
+	startMessage := "Press to Start"
+	x, y := g.centerText(startMessage, smallArcadeFont)
+	text.Draw(screen, startMessage, smallArcadeFont, x, y+20, color.Black)
+	if g.online {
+		return
 	}
-
-	for _, touchID := range ebiten.TouchIDs() {
-		x, y := ebiten.TouchPosition(touchID)
-		if x > int(g.WindowWidth/2) {
-			if y > int(g.RightPaddle.Y+g.RightPaddle.H/2) {
-				return true
-			}
-		}
+	controlsMessage := "F1 for Controls"
+	x, _ = g.centerText(controlsMessage, smallArcadeFont)
+	text.Draw(screen, controlsMessage, smallArcadeFont, x, y+20+smallFontSize*2, color.Black)
+	if g.State.Mode == sim.ModeWait {
+		cpuMessage := g.cpuMessage()
+		x, _ = g.centerText(cpuMessage, smallArcadeFont)
+		text.Draw(screen, cpuMessage, smallArcadeFont, x, y+20+smallFontSize*4, color.Black)
+	}
+	if g.pickLevel() {
+		levelMessage := g.levelMessage()
+		x, _ = g.centerText(levelMessage, smallArcadeFont)
+		text.Draw(screen, levelMessage, smallArcadeFont, x, y+20+smallFontSize*6, color.Black)
 	}
-	return false
 }
 
-func keyPressStartGame() bool {
-	keys := []ebiten.Key{ebiten.KeySpace, ebiten.KeyW, ebiten.KeyS, ebiten.KeyUp, ebiten.KeyDown, ebiten.KeyEnter}
-	for _, key := range keys {
-		if inpututil.IsKeyJustPressed(key) {
-			return true
-		}
+func (g *Game) Draw(screen *ebiten.Image) {
+	g.drawBackground(screen)
+	if g.rebind != nil {
+		g.rebind.Draw(screen)
+		return
 	}
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
-		return true
+	ebitenutil.DebugPrint(screen, fmt.Sprintf("FPS: %+v, TPS: %+v", ebiten.CurrentFPS(), ebiten.CurrentTPS()))
+	if g.spectators != nil {
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Spectators: %d", g.spectators.Viewers()), 0, 16)
 	}
-	if len(inpututil.JustPressedTouchIDs()) > 0 {
-		return true
+	g.drawScores(screen)
+	g.drawEffects(screen)
+	view := g.View.GeoM()
+	if g.State.Mode != sim.ModeOver {
+		g.drawArena(screen, view)
 	}
-	return false
-}
-
-func (g *Game) Update(screen *ebiten.Image) error {
-	if g.GameMode == gameModePause {
-		if keyPressStartGame() {
-			g.GameMode = gameModePlay
+	switch g.State.Mode {
+	case sim.ModeWait:
+		g.drawStart(screen)
+	case sim.ModePlay:
+		g.drawPaddles(screen, view)
+		g.drawPowerUps(screen, view)
+		drawBalls(screen, view, &g.State, &g.trails)
+	case sim.ModePause:
+		g.drawStart(screen)
+		g.drawPaddles(screen, view)
+		g.drawPowerUps(screen, view)
+		drawBalls(screen, view, &g.State, &g.trails)
+	case sim.ModeServe:
+		g.drawServe(screen)
+		g.drawPaddles(screen, view)
+		g.drawPowerUps(screen, view)
+		drawBalls(screen, view, &g.State, &g.trails)
+	case sim.ModeOver:
+		g.drawOver(screen)
+	}
+	g.drawNotice(screen)
+}
+
+// scoreSpots are where each player's score goes in a four player match, in
+// arena units.
+var scoreSpots = [sim.MaxSides]sim.Pair{
+	sim.Left:   {X: sim.ArenaWidth * 0.15, Y: sim.ArenaHeight / 2},
+	sim.Right:  {X: sim.ArenaWidth * 0.85, Y: sim.ArenaHeight / 2},
+	sim.Top:    {X: sim.ArenaWidth / 2, Y: sim.ArenaHeight * 0.25},
+	sim.Bottom: {X: sim.ArenaWidth / 2, Y: sim.ArenaHeight * 0.75},
+}
+
+// drawScores shows the scores, or the lives left when playing for lives.
+// Two scores go in the middle; four go by their players' walls.
+func (g *Game) drawScores(screen *ebiten.Image) {
+	s := &g.State
+	values := s.Score
+	if s.Config.Rules.Lives > 0 {
+		values = s.Lives
+	}
+	if s.Config.Sides() == 2 {
+		score := g.perSide(values)
+		x, y := g.centerText(score, arcadeFont)
+		text.Draw(screen, score, arcadeFont, x, y, color.Black)
+		if s.Config.Rules.Sets > 1 && s.Config.Rules.Lives == 0 {
+			sets := "Sets " + g.perSide(s.Sets)
+			x, _ := g.centerText(sets, smallArcadeFont)
+			text.Draw(screen, sets, smallArcadeFont, x, y-fontSize, color.Black)
 		}
-		return nil
+		return
 	}
-	if g.GameMode == gameModeWait {
-		if keyPressStartGame() {
-			g.startGame()
-		}
-		return nil
 
+	view := g.View.GeoM()
+	for side := sim.Side(0); side < sim.MaxSides; side++ {
+		value := fmt.Sprint(values[side])
+		if !s.InPlay(side) {
+			value = "Out"
+		}
+		x, y := view.Apply(scoreSpots[side].X, scoreSpots[side].Y)
+		size := future.MeasureString(value, arcadeFont)
+		text.Draw(screen, value, arcadeFont, int(x)-size.X/2, int(y)+size.Y/2, color.Black)
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyEscape) {
-		g.GameMode = gameModePause
-		return nil
-	}
+}
 
-	if g.LeftPaddleUp() {
-		g.LeftPaddle.Y += -g.PaddleSpeed
-	}
-	if g.LeftPaddleDown() {
-		g.LeftPaddle.Y += g.PaddleSpeed
-	}
-	if g.RightPaddleUp() {
-		g.RightPaddle.Y += -g.PaddleSpeed
-	}
-	if g.RightPaddleDown() {
-		g.RightPaddle.Y += g.PaddleSpeed
+// perSide lists one value for each player in the match, like "3 - 2".
+func (g *Game) perSide(values [sim.MaxSides]int) string {
+	parts := make([]string, g.State.Config.Sides())
+	for i := range parts {
+		parts[i] = fmt.Sprint(values[i])
 	}
+	return strings.Join(parts, " - ")
+}
 
-	if int32(g.Ball.Coord.X)+g.Ball.Radius > g.WindowWidth && g.Ball.Velocity.X > 0 {
-		g.Score.Y += 1
-		// g.Ball.Velocity.X *= -1
-		g.GameMode = gameModeWait
+func (g *Game) playerName(side sim.Side) string {
+	if g.names[side] != "" {
+		return g.names[side]
 	}
-	if g.Ball.Coord.X < 0 && g.Ball.Velocity.X < 0 {
-		g.Score.X += 1
-		// g.Ball.Velocity.X *= -1
-		g.GameMode = gameModeWait
+	switch side {
+	case sim.Right:
+		return "Right player"
+	case sim.Top:
+		return "Top player"
+	case sim.Bottom:
+		return "Bottom player"
 	}
-	g.HandleBallPaddleCollision()
+	return "Left player"
+}
 
-	if g.Ball.Coord.Y < 0 && g.Ball.Velocity.Y < 0 {
-		g.Ball.Velocity.Y = -g.Ball.Velocity.Y
-		g.Ball.trail.UpdateAngle(g.Ball.Velocity)
+// drawServe counts down to the serve, or says whose serve it is.
+func (g *Game) drawServe(screen *ebiten.Image) {
+	s := &g.State
+	if !s.Config.Serve.Manual && s.Countdown <= 0 {
+		return
 	}
-	if int32(g.Ball.Coord.Y)+g.Ball.Radius > g.WindowHeight && g.Ball.Velocity.Y > 0 {
-		g.Ball.Velocity.Y = -g.Ball.Velocity.Y
-		g.Ball.trail.UpdateAngle(g.Ball.Velocity)
+	message := fmt.Sprint((s.Countdown + sim.TickRate - 1) / sim.TickRate)
+	if s.Config.Serve.Manual {
+		message = g.playerName(s.Server) + " to Serve"
 	}
-	g.Ball.Coord.X += g.Ball.Velocity.X
-	g.Ball.Coord.Y += g.Ball.Velocity.Y
-	g.Ball.trail.Add(&g.Ball)
-	return nil
+	x, y := g.centerText(message, smallArcadeFont)
+	text.Draw(screen, message, smallArcadeFont, x, y+20+smallFontSize*2, color.Black)
 }
 
-func (g *Game) PaddleDimensions() (int, int) {
-	return int(g.WindowWidth / 100), int(g.WindowHeight / 5)
-}
-
-func GetBounceVelocity(Paddle *resolv.Rectangle, Ball *resolv.Circle, maxSpeed float64) (float64, float64) {
-	relativeIntersect := float64(Paddle.Y) + (float64(Paddle.H) / 2) - (float64(Ball.Y) + float64(Ball.Radius)/2)
-	normalizedRelativeIntersect := (relativeIntersect / (float64(Paddle.H) / 2))
-	angle := normalizedRelativeIntersect * math.Pi / 4
-	return Abs(math.Cos(angle)) * maxSpeed * Abs(angle), -math.Sin(angle) * maxSpeed * Abs(angle)
-}
+// drawOver shows who won the match and how it went.
+func (g *Game) drawOver(screen *ebiten.Image) {
+	s := &g.State
+	winner := g.playerName(s.Winner) + " wins"
+	x, y := g.centerText(winner, arcadeFont)
+	text.Draw(screen, winner, arcadeFont, x, y-fontSize*3, color.Black)
 
-func (g *Game) HandleBallPaddleCollision() {
-	oldX := g.LeftPaddle.X
-	g.LeftPaddle.X -= g.LeftPaddle.W
-	resolution := resolv.Resolve(g.Ball.BoundingBox(), &g.LeftPaddle, int32(g.Ball.Velocity.X), int32(g.Ball.Velocity.Y))
-	g.LeftPaddle.X = oldX
-
-	if resolution.Colliding() && g.Ball.Velocity.X < 0 {
-		vx, vy := GetBounceVelocity(&g.LeftPaddle, g.Ball.BoundingBox(), g.Ball.VelocityBounds.Y)
-		g.Ball.Velocity.X = vx
-		g.Ball.Velocity.Y = vy
-		hitPlayer.Rewind()
-		hitPlayer.Play()
-		g.Ball.trail.UpdateAngle(g.Ball.Velocity)
+	seconds := s.Stats.Ticks / sim.TickRate
+	lines := []string{
+		"Points " + g.perSide(s.Stats.Points),
+		"Hits " + g.perSide(s.Stats.Hits),
+		fmt.Sprintf("Longest rally %d", s.Stats.LongestRally),
+		fmt.Sprintf("Time %d:%02d", seconds/60, seconds%60),
 	}
-
-	oldX = g.RightPaddle.X
-	g.RightPaddle.X += g.RightPaddle.W
-	resolution = resolv.Resolve(g.Ball.BoundingBox(), &g.RightPaddle, int32(g.Ball.Velocity.X), int32(g.Ball.Velocity.Y))
-	g.RightPaddle.X = oldX
-
-	if resolution.Colliding() && g.Ball.Velocity.X > 0 {
-		vx, vy := GetBounceVelocity(&g.RightPaddle, g.Ball.BoundingBox(), g.Ball.VelocityBounds.Y)
-		g.Ball.Velocity.X = -(vx)
-		g.Ball.Velocity.Y = vy
-		hitPlayer.Rewind()
-		hitPlayer.Play()
-		g.Ball.trail.UpdateAngle(g.Ball.Velocity)
-	}
-
-	if Abs(g.Ball.Velocity.X) < g.Ball.VelocityBounds.X {
-		multiplier := 1.0
-		if g.Ball.Velocity.X < 0 {
-			multiplier = -1
+	if !g.watching {
+		prompt := "Press to Start a Rematch"
+		if g.overPrompt != "" {
+			prompt = g.overPrompt
 		}
-		g.Ball.Velocity.X = g.Ball.VelocityBounds.X * multiplier
+		lines = append(lines, "", prompt)
 	}
-}
-
-func (g *Game) startGame() {
-	g.Reset()
-	g.GameMode = gameModePlay
-}
-
-func (g *Game) centerText(content string, face font.Face) (int, int) {
-	centerX, centerY := int(g.WindowWidth/2), int(g.WindowHeight/2)
-
-	stringSize := future.MeasureString(content, face)
-	centerX -= stringSize.X / 2
-	centerY -= stringSize.Y / 2
-
-	return centerX, centerY
-
-}
-
-func (g *Game) drawStart(screen *ebiten.Image) {
-	startMessage := "Press to Start"
-	x, y := g.centerText(startMessage, smallArcadeFont)
-	text.Draw(screen, startMessage, smallArcadeFont, x, y+20, color.Black)
-}
-
-func (g *Game) Draw(screen *ebiten.Image) {
-	g.drawBackground(screen)
-	ebitenutil.DebugPrint(screen, fmt.Sprintf("FPS: %+v, TPS: %+v", ebiten.CurrentFPS(), ebiten.CurrentTPS()))
-	score := fmt.Sprintf("%+v - %+v", int(g.Score.X), int(g.Score.Y))
-	x, y := g.centerText(score, arcadeFont)
-	text.Draw(screen, score, arcadeFont, x, y, color.Black)
-	switch g.GameMode {
-	case gameModeWait:
-		g.drawStart(screen)
-	case gameModePlay:
-		g.drawPaddles(screen)
-		g.Ball.Draw(screen)
-	case gameModePause:
-		g.drawStart(screen)
-		g.drawPaddles(screen)
-		g.Ball.Draw(screen)
+	_, y = g.centerText(winner, smallArcadeFont)
+	for i, line := range lines {
+		x, _ := g.centerText(line, smallArcadeFont)
+		text.Draw(screen, line, smallArcadeFont, x, y+20+smallFontSize*2*i, color.Black)
 	}
 }
 
 func (g *Game) drawBackground(screen *ebiten.Image) {
 	backgroundOpts := ebiten.DrawImageOptions{}
 	w, h := backgroundImage.Size()
-	backgroundOpts.GeoM.Scale(float64(g.WindowWidth)/float64(w), float64(g.WindowHeight)/float64(h))
+	backgroundOpts.GeoM.Scale(float64(g.View.WindowWidth)/float64(w), float64(g.View.WindowHeight)/float64(h))
 	screen.DrawImage(backgroundImage, &backgroundOpts)
 }
 
-func (g *Game) drawPaddles(screen *ebiten.Image) {
+// drawPaddles draws the paddles in play, and a wall where a player of a
+// four player match is out.
+func (g *Game) drawPaddles(screen *ebiten.Image, view ebiten.GeoM) {
 	paddleImage.Fill(color.White)
 
-	paddleOpts := ebiten.DrawImageOptions{}
-
-	paddleOpts.GeoM.Scale(float64(g.LeftPaddle.W), float64(g.LeftPaddle.H))
-	paddleOpts.GeoM.Translate(float64(g.LeftPaddle.X), float64(g.LeftPaddle.Y))
-	screen.DrawImage(paddleImage, &paddleOpts)
-
-	paddleOpts.GeoM.Reset()
-	paddleOpts.GeoM.Scale(float64(g.RightPaddle.W), float64(g.RightPaddle.H))
-	paddleOpts.GeoM.Translate(float64(g.RightPaddle.X), float64(g.RightPaddle.Y))
-	screen.DrawImage(paddleImage, &paddleOpts)
-
+	s := &g.State
+	thickness := s.Config.PaddleWidth
+	walls := [sim.MaxSides]sim.Rect{
+		sim.Left:   {W: thickness, H: sim.ArenaHeight},
+		sim.Right:  {X: sim.ArenaWidth - thickness, W: thickness, H: sim.ArenaHeight},
+		sim.Top:    {W: sim.ArenaWidth, H: thickness},
+		sim.Bottom: {Y: sim.ArenaHeight - thickness, W: sim.ArenaWidth, H: thickness},
+	}
+	for side := sim.Side(0); side < sim.Side(s.Config.Sides()); side++ {
+		rect := walls[side]
+		if s.InPlay(side) {
+			rect = s.Paddle(side).Rect
+		}
+		paddleOpts := ebiten.DrawImageOptions{}
+		paddleOpts.GeoM.Scale(rect.W, rect.H)
+		paddleOpts.GeoM.Translate(rect.X, rect.Y)
+		paddleOpts.GeoM.Concat(view)
+		screen.DrawImage(paddleImage, &paddleOpts)
+	}
 }
 
+// Layout only records the window size for the view transform; the arena
+// itself never changes size, so resizing does not interrupt a match.
 func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
-	if g.WindowWidth != int32(outsideWidth) || g.WindowHeight != int32(outsideHeight) {
-		g.WindowWidth = int32(outsideWidth)
-		g.WindowHeight = int32(outsideHeight)
-		g.GameMode = gameModeWait
-	}
+	g.View.WindowWidth = outsideWidth
+	g.View.WindowHeight = outsideHeight
 	return outsideWidth, outsideHeight
 }
 
@@ -580,10 +739,228 @@ func main() {
 	// try to handle os interrupt(signal terminated)
 	go onKill(c)
 
+	if flag.NArg() > 0 {
+		if err := runCommand(flag.Arg(0), flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	runWindow()
+}
+
+// runCommand runs one of the subcommands that replace the normal local game.
+func runCommand(name string, args []string) error {
+	switch name {
+	case "env":
+		return runEnv(args)
+	case "replay":
+		return runReplay(args)
+	case "host":
+		return runHost(args)
+	case "join":
+		return runJoin(args)
+	case "server":
+		return runServer(args)
+	case "connect":
+		return runConnect(args)
+	case "spectate":
+		return runSpectate(args)
+	case "lobby":
+		return runLobby(args)
+	case "find":
+		return runFind(args)
+	case "tournament":
+		return runTournament(args)
+	case "edit":
+		return runEdit(args)
+	}
+	return fmt.Errorf("unknown command %q", name)
+}
+
+// userBindings loads the player's bindings and returns them with the path
+// changes should be saved to. It also checks the other input flags.
+func userBindings() (bindings.Bindings, string) {
+	path := *bindingsFile
+	if path == "" {
+		// Not every platform has a config directory, the browser in
+		// particular; the defaults are used there and nothing is saved.
+		path, _ = bindings.DefaultPath()
+	}
+	b := bindings.Default()
+	if path != "" {
+		var err error
+		if b, err = loadBindings(path); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	switch *pointer {
+	case pointerHold, pointerFollow, pointerSpinner:
+	default:
+		log.Fatalf("unknown pointer mode %q", *pointer)
+	}
+	if *deadZone < 0 || *deadZone >= 1 {
+		log.Fatalf("-deadzone must be at least 0 and less than 1, not %g", *deadZone)
+	}
+	return b, path
+}
+
+var serveRules = map[string]sim.ServeRule{
+	"alternate": sim.ServeAlternate,
+	"loser":     sim.ServeLoser,
+	"winner":    sim.ServeWinner,
+}
+
+// oneOnOneConfig is matchConfig for the kinds of match, named by what, that
+// only pit two players against each other.
+func oneOnOneConfig(what string) (sim.Config, error) {
+	config, err := matchConfig()
+	if err == nil && config.Players != 2 {
+		err = fmt.Errorf("%s are one on one, -players must be 2", what)
+	}
+	return config, err
+}
+
+// matchConfig is the simulation config and match rules set by the command
+// line flags.
+func matchConfig() (sim.Config, error) {
+	config := sim.DefaultConfig()
+	config.TrackSpeed = config.PaddleSpeed * *trackSpeed
+	if *points < 0 || *winBy < 1 || *sets < 1 {
+		return config, errors.New("-points must not be negative and -win-by and -sets must be at least 1")
+	}
+	if *sets%2 == 0 {
+		return config, fmt.Errorf("-sets must be odd to play the best of them, not %d", *sets)
+	}
+	if *players != 2 && *players != sim.MaxSides {
+		return config, fmt.Errorf("-players must be 2 or 4, not %d", *players)
+	}
+	if *lives < 0 {
+		return config, errors.New("-lives must not be negative")
+	}
+	config.Players = *players
+	config.Rules = sim.Rules{Points: *points, WinBy: *winBy, Sets: *sets, Lives: *lives}
+
+	rule, ok := serveRules[*serveRule]
+	if !ok {
+		return config, fmt.Errorf("-serve must be alternate, loser or winner, not %q", *serveRule)
+	}
+	maxAngle := sim.MaxServeCone * 180 / math.Pi
+	if *serveAngle < 0 || *serveAngle > maxAngle {
+		return config, fmt.Errorf("-serve-angle must be between 0 and %.0f degrees", maxAngle)
+	}
+	if *countdown < 0 {
+		return config, errors.New("-countdown must not be negative")
+	}
+	config.Serve = sim.Serve{
+		Rule:      rule,
+		Cone:      *serveAngle * math.Pi / 180,
+		Countdown: *countdown,
+		Manual:    *manualServe,
+	}
+
+	if *balls < 1 || *balls > sim.MaxBalls {
+		return config, fmt.Errorf("-balls must be between 1 and %d", sim.MaxBalls)
+	}
+	if *spawnEvery < 0 || *spawnHits < 0 {
+		return config, errors.New("-spawn-every and -spawn-hits must not be negative")
+	}
+	config.MultiBall = sim.MultiBall{
+		Balls:     *balls,
+		Interval:  *spawnEvery,
+		EveryHits: *spawnHits,
+		FirstGoal: *firstGoal,
+	}
+
+	kinds, err := parsePowerUps(*powerUps)
+	if err != nil {
+		return config, fmt.Errorf("-powerups: %v", err)
+	}
+	if *powerUpEvery <= 0 || *powerUpTime <= 0 {
+		return config, errors.New("-powerup-every and -powerup-time must be positive")
+	}
+	config.PowerUps = sim.PowerUps{Kinds: kinds, Interval: *powerUpEvery, Duration: *powerUpTime}
+
+	l, err := matchLevel()
+	if err != nil {
+		return config, err
+	}
+	if config.Arena, err = l.Arena(); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// matchSeed is the -seed flag, or one based on the time if it isn't set.
+func matchSeed() int64 {
+	if *seed == 0 {
+		return time.Now().UnixNano()
+	}
+	return *seed
+}
+
+func runWindow() {
+	var err error
+
+	b, path := userBindings()
+	if !validCPU(*cpu) {
+		log.Fatalf("-ai must be left, right, top or bottom, not %q", *cpu)
+	}
+	cpuLevel, ok := control.DifficultyByName(*difficulty)
+	if !ok {
+		log.Fatalf("unknown difficulty %q", *difficulty)
+	}
+	*seed = matchSeed()
+	config, err := matchConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *cpu != cpuOff && int(cpuSide(*cpu)) >= config.Sides() {
+		log.Fatalf("-ai %s needs -players 4", *cpu)
+	}
+	l, err := matchLevel()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var start *snapshot.Snapshot
+	if *load != "" {
+		if start, err = snapshot.Load(*load); err != nil {
+			log.Fatal(err)
+		}
+	}
+	snapshotPath := *snapshotFile
+	if snapshotPath == "" {
+		snapshotPath, _ = snapshot.DefaultPath()
+	}
+	spectators, err := startSpectating()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	loadResources()
+	ebiten.SetMaxTPS(*tps)
 	ebiten.SetWindowResizable(true)
 	ebiten.SetWindowTitle("Pong, but shitty")
 
-	if err := ebiten.RunGame(NewGame()); err != nil {
+	g := NewGame(Options{
+		Config:       config,
+		Bindings:     b,
+		BindingsPath: path,
+		DeadZone:     *deadZone,
+		Pointer:      *pointer,
+		CPU:          *cpu,
+		Difficulty:   cpuLevel,
+		Seed:         *seed,
+		Record:       *record,
+		Snapshot:     start,
+		SnapshotPath: snapshotPath,
+		Spectators:   spectators,
+		Level:        l.Name,
+	})
+	err = ebiten.RunGame(g)
+	g.saveRecording()
+	if err != nil {
 		fmt.Println(err)
 		log.Fatal(err)
 	}
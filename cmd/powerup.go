@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/text"
+
+	"github.com/fabianvf/pong-golang/pkg/future"
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+var powerColors = [sim.NumPowers]color.RGBA{
+	sim.PowerGrow:      {0x2e, 0xa0, 0x43, 0xff},
+	sim.PowerShrink:    {0xd0, 0x3a, 0x2f, 0xff},
+	sim.PowerSpeed:     {0xf0, 0x8c, 0x1a, 0xff},
+	sim.PowerSticky:    {0x8e, 0x44, 0xad, 0xff},
+	sim.PowerSplit:     {0x17, 0xa2, 0xb8, 0xff},
+	sim.PowerSlow:      {0x2c, 0x5e, 0xd1, 0xff},
+	sim.PowerInvisible: {0x70, 0x70, 0x70, 0xff},
+}
+
+// powerMarks are drawn on the power-ups to tell them apart.
+var powerMarks = [sim.NumPowers]string{
+	sim.PowerGrow:      "+",
+	sim.PowerShrink:    "-",
+	sim.PowerSpeed:     ">",
+	sim.PowerSticky:    "#",
+	sim.PowerSplit:     "2",
+	sim.PowerSlow:      "<",
+	sim.PowerInvisible: "?",
+}
+
+// glimpseTicks is how often an invisible ball flashes into view, and
+// glimpseShown for how long.
+const (
+	glimpseTicks = sim.TickRate / 2
+	glimpseShown = sim.TickRate / 20
+)
+
+// glimpse reports whether invisible balls are showing this tick.
+func glimpse(s *sim.State) bool {
+	return s.Stats.Ticks%glimpseTicks < glimpseShown
+}
+
+// parsePowerUps reads a comma separated list of power-ups, or "all".
+func parsePowerUps(list string) ([sim.NumPowers]bool, error) {
+	var kinds [sim.NumPowers]bool
+	if list == "" {
+		return kinds, nil
+	}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "all" {
+			for k := range kinds {
+				kinds[k] = true
+			}
+			continue
+		}
+		k, ok := sim.PowerByName(name)
+		if !ok {
+			return kinds, fmt.Errorf("unknown power-up %q", name)
+		}
+		kinds[k] = true
+	}
+	return kinds, nil
+}
+
+// drawPowerUps draws each power-up waiting in the arena as a coloured block
+// with its mark.
+func (g *Game) drawPowerUps(screen *ebiten.Image, view ebiten.GeoM) {
+	for _, p := range g.State.PowerUps {
+		if !p.Live {
+			continue
+		}
+		c := powerColors[p.Kind]
+		ballImage.Fill(color.White)
+		opts := ebiten.DrawImageOptions{}
+		opts.ColorM.Scale(float64(c.R)/0xff, float64(c.G)/0xff, float64(c.B)/0xff, 1)
+		opts.GeoM.Scale(2*sim.PowerUpRadius, 2*sim.PowerUpRadius)
+		opts.GeoM.Translate(p.Coord.X-sim.PowerUpRadius, p.Coord.Y-sim.PowerUpRadius)
+		opts.GeoM.Concat(view)
+		screen.DrawImage(ballImage, &opts)
+
+		mark := powerMarks[p.Kind]
+		x, y := view.Apply(p.Coord.X, p.Coord.Y)
+		size := future.MeasureString(mark, smallArcadeFont)
+		text.Draw(screen, mark, smallArcadeFont, int(x)-size.X/2, int(y)+size.Y/2, color.White)
+	}
+}
+
+// effectSpots are where the effects on each player are listed, in arena
+// units: under the scores in a four player match, and by the top corners
+// otherwise.
+var effectSpots = [2][sim.MaxSides]sim.Pair{
+	{
+		sim.Left:  {X: sim.ArenaWidth * 0.15, Y: sim.ArenaHeight * 0.1},
+		sim.Right: {X: sim.ArenaWidth * 0.85, Y: sim.ArenaHeight * 0.1},
+	},
+	{
+		sim.Left:   {X: scoreSpots[sim.Left].X, Y: scoreSpots[sim.Left].Y + sim.ArenaHeight*0.08},
+		sim.Right:  {X: scoreSpots[sim.Right].X, Y: scoreSpots[sim.Right].Y + sim.ArenaHeight*0.08},
+		sim.Top:    {X: scoreSpots[sim.Top].X, Y: scoreSpots[sim.Top].Y + sim.ArenaHeight*0.08},
+		sim.Bottom: {X: scoreSpots[sim.Bottom].X, Y: scoreSpots[sim.Bottom].Y + sim.ArenaHeight*0.08},
+	},
+}
+
+// drawEffects lists the power-ups working on each player with how many
+// times they have stacked and the seconds they have left.
+func (g *Game) drawEffects(screen *ebiten.Image) {
+	s := &g.State
+	spots := &effectSpots[0]
+	if s.Config.Sides() == sim.MaxSides {
+		spots = &effectSpots[1]
+	}
+	view := g.View.GeoM()
+	for side := sim.Side(0); side < sim.Side(s.Config.Sides()); side++ {
+		x, y := view.Apply(spots[side].X, spots[side].Y)
+		line := 0
+		for kind, e := range s.Effects[side] {
+			if e.Ticks == 0 {
+				continue
+			}
+			label := strings.Title(sim.PowerKind(kind).String())
+			if e.Stacks > 1 {
+				label += fmt.Sprintf(" x%d", e.Stacks)
+			}
+			label += fmt.Sprintf(" %ds", (e.Ticks+sim.TickRate-1)/sim.TickRate)
+			size := future.MeasureString(label, smallArcadeFont)
+			left := int(x) - size.X/2
+			if left < 0 {
+				left = 0
+			} else if left+size.X > g.View.WindowWidth {
+				left = g.View.WindowWidth - size.X
+			}
+			text.Draw(screen, label, smallArcadeFont, left, int(y)+line*smallFontSize*3/2, powerColors[kind])
+			line++
+		}
+	}
+}
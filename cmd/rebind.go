@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
+	"github.com/hajimehoshi/ebiten/text"
+
+	"github.com/fabianvf/pong-golang/pkg/bindings"
+)
+
+var actionLabels = map[bindings.Action]string{
+	bindings.LeftUp:      "LEFT UP",
+	bindings.LeftDown:    "LEFT DOWN",
+	bindings.RightUp:     "RIGHT UP",
+	bindings.RightDown:   "RIGHT DOWN",
+	bindings.TopLeft:     "TOP LEFT",
+	bindings.TopRight:    "TOP RIGHT",
+	bindings.BottomLeft:  "BOTTOM LEFT",
+	bindings.BottomRight: "BOTTOM RIGHT",
+	bindings.Start:       "START",
+	bindings.Pause:       "PAUSE",
+}
+
+// rebindScreen lets players change their key bindings in game. Up and down
+// pick an action, enter replaces its inputs with the next key or gamepad
+// button pressed, tab adds another one, and F1 saves and closes. Escape
+// always backs out: of waiting for a key, or otherwise of the screen,
+// throwing away the changes. So it can't be bound here, though it stays
+// one of the default pause keys.
+type rebindScreen struct {
+	bindings  bindings.Bindings
+	selected  int
+	capturing bool
+	adding    bool
+	message   string
+}
+
+func newRebindScreen(b bindings.Bindings) *rebindScreen {
+	copied := bindings.Bindings{}
+	for action, keys := range b {
+		copied[action] = append([]string{}, keys...)
+	}
+	return &rebindScreen{bindings: copied}
+}
+
+func justPressedKey() (ebiten.Key, bool) {
+	for k := ebiten.Key(0); k <= ebiten.KeyMax; k++ {
+		if inpututil.IsKeyJustPressed(k) {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+// Update handles one tick of input and reports whether the screen should
+// close, and if so whether its bindings should be applied.
+func (r *rebindScreen) Update() (closed, save bool) {
+	action := bindings.Actions[r.selected]
+	if r.capturing {
+		var input string
+		if key, ok := justPressedKey(); ok {
+			input = key.String()
+		} else if button, ok := justPressedGamepadButton(); ok {
+			input = gamepadButtonName(button)
+		} else {
+			return false, false
+		}
+		r.capturing = false
+		r.message = ""
+		if input == ebiten.KeyEscape.String() {
+			return false, false
+		}
+		if r.adding {
+			r.bindings[action] = append(r.bindings[action], input)
+		} else {
+			r.bindings[action] = []string{input}
+		}
+		return false, false
+	}
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyUp):
+		r.selected = (r.selected + len(bindings.Actions) - 1) % len(bindings.Actions)
+	case inpututil.IsKeyJustPressed(ebiten.KeyDown):
+		r.selected = (r.selected + 1) % len(bindings.Actions)
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		r.capturing, r.adding = true, false
+	case inpututil.IsKeyJustPressed(ebiten.KeyTab):
+		r.capturing, r.adding = true, true
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		return true, false
+	case inpututil.IsKeyJustPressed(ebiten.KeyF1):
+		if err := r.bindings.Validate(knownInput); err != nil {
+			r.message = err.Error()
+			return false, false
+		}
+		return true, true
+	}
+	return false, false
+}
+
+func (r *rebindScreen) Draw(screen *ebiten.Image) {
+	const lineHeight = smallFontSize * 3 / 2
+	x, y := smallFontSize*2, smallFontSize*3
+	text.Draw(screen, "CONTROLS", arcadeFont, x, y, color.Black)
+	y += lineHeight * 2
+	for i, action := range bindings.Actions {
+		keys := strings.ToUpper(strings.Join(r.bindings[action], ", "))
+		if i == r.selected && r.capturing {
+			keys = "PRESS A KEY OR BUTTON"
+		}
+		line := fmt.Sprintf("%-12s %s", actionLabels[action], keys)
+		if i == r.selected {
+			line = "> " + line
+		} else {
+			line = "  " + line
+		}
+		text.Draw(screen, line, smallArcadeFont, x, y, color.Black)
+		y += lineHeight
+	}
+	y += lineHeight
+	text.Draw(screen, "ENTER SET  TAB ADD  F1 SAVE  ESC CANCEL", smallArcadeFont, x, y, color.Black)
+	if r.message != "" {
+		y += lineHeight
+		text.Draw(screen, strings.ToUpper(r.message), smallArcadeFont, x, y, color.Black)
+	}
+}
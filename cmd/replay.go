@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
+	"github.com/hajimehoshi/ebiten/text"
+
+	"github.com/fabianvf/pong-golang/pkg/replay"
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+const (
+	replayMinSpeed = 1.0 / 8
+	replayMaxSpeed = 8
+	replaySeekStep = 5 * sim.TickRate
+)
+
+// replayViewer plays a replay in the window. Space pauses, up and down
+// change the speed, left and right seek, or step a single tick while
+// paused, and home goes back to the start.
+type replayViewer struct {
+	*Game
+	player *replay.Player
+	speed  float64
+	paused bool
+}
+
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: pong replay <file>")
+	}
+	r, err := replay.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	loadResources()
+	ebiten.SetMaxTPS(*tps)
+	ebiten.SetWindowResizable(true)
+	ebiten.SetWindowTitle("Pong replay")
+
+	g := NewGame(Options{Config: r.Config, Seed: r.Seed})
+	g.watching = true
+	v := &replayViewer{Game: g, player: replay.NewPlayer(r), speed: 1}
+	v.State = v.player.State
+	return ebiten.RunGame(v)
+}
+
+func (v *replayViewer) Update(screen *ebiten.Image) error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyF11) {
+		ebiten.SetFullscreen(!ebiten.IsFullscreen())
+	}
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeySpace):
+		v.paused = !v.paused
+	case inpututil.IsKeyJustPressed(ebiten.KeyUp):
+		if v.speed < replayMaxSpeed {
+			v.speed *= 2
+		}
+	case inpututil.IsKeyJustPressed(ebiten.KeyDown):
+		if v.speed > replayMinSpeed {
+			v.speed /= 2
+		}
+	case inpututil.IsKeyJustPressed(ebiten.KeyRight):
+		if v.paused {
+			v.react(v.player.Step())
+			v.State = v.player.State
+		} else {
+			v.seek(v.player.Tick() + replaySeekStep)
+		}
+	case inpututil.IsKeyJustPressed(ebiten.KeyLeft):
+		if v.paused {
+			v.seek(v.player.Tick() - 1)
+		} else {
+			v.seek(v.player.Tick() - replaySeekStep)
+		}
+	case inpututil.IsKeyJustPressed(ebiten.KeyHome):
+		v.seek(0)
+	}
+
+	elapsed := v.frameTime() * v.speed
+	if v.paused {
+		return nil
+	}
+	steps := v.clock.Advance(elapsed)
+	for i := 0; i < steps && !v.player.Done(); i++ {
+		events := v.player.Step()
+		v.State = v.player.State
+		v.react(events)
+	}
+	return nil
+}
+
+func (v *replayViewer) seek(tick int) {
+	v.player.Seek(tick)
+	v.State = v.player.State
+	v.trails = NewTrails(&v.State.Balls)
+}
+
+func formatTicks(ticks int) string {
+	seconds := ticks / sim.TickRate
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+func (v *replayViewer) Draw(screen *ebiten.Image) {
+	v.Game.Draw(screen)
+	status := fmt.Sprintf("%s / %s  X%g", formatTicks(v.player.Tick()), formatTicks(v.player.Ticks()), v.speed)
+	if v.paused {
+		status += "  PAUSED"
+	}
+	if v.player.Done() {
+		status += "  END"
+	}
+	text.Draw(screen, status, smallArcadeFont, smallFontSize, v.View.WindowHeight-smallFontSize, color.Black)
+}
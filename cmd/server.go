@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"time"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
+
+	"github.com/fabianvf/pong-golang/pkg/control"
+	"github.com/fabianvf/pong-golang/pkg/server"
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// runServer implements "pong server": a dedicated server that owns the
+// match. Native clients and browsers connect to the same port.
+func runServer(args []string) error {
+	fs := flag.NewFlagSet("server", flag.ExitOnError)
+	listen := fs.String("listen", ":7780", "TCP address to accept players on")
+	fs.Parse(args)
+
+	config, err := oneOnOneConfig("server matches")
+	if err != nil {
+		return err
+	}
+	return server.New(config, matchSeed()).ListenAndServe(*listen)
+}
+
+// serverGame plays on a pong server. Either set of keys moves the local
+// paddle.
+type serverGame struct {
+	*Game
+	client *server.Client
+	err    error
+}
+
+// runConnect implements "pong connect". The browser build runs it when the
+// page is opened with ?server=host:port.
+func runConnect(args []string) error {
+	fs := flag.NewFlagSet("connect", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: pong connect <host:port>")
+	}
+
+	conn, err := dialServer(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	c, err := server.Connect(conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	b, path := userBindings()
+
+	loadResources()
+	ebiten.SetMaxTPS(*tps)
+	ebiten.SetWindowResizable(true)
+	ebiten.SetWindowTitle("Pong online")
+
+	g := NewGame(Options{
+		Config:       c.Config,
+		Bindings:     b,
+		BindingsPath: path,
+		DeadZone:     *deadZone,
+		Pointer:      *pointer,
+	})
+	g.online = true
+	g.Gamepads.Share(c.Side)
+	return ebiten.RunGame(&serverGame{Game: g, client: c})
+}
+
+func (s *serverGame) Update(screen *ebiten.Image) error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyF11) {
+		ebiten.SetFullscreen(!ebiten.IsFullscreen())
+	}
+	s.Gamepads.Update()
+	s.spinner.Poll()
+	steps := s.clock.Advance(s.frameTime())
+	for i := 0; i < steps && s.err == nil; i++ {
+		local := control.Multi{s.Controllers[sim.Left], s.Controllers[sim.Right]}
+		if err := s.client.Send(local.Intent(&s.State, s.client.Side)); err != nil {
+			log.Print(err)
+			s.err = err
+		}
+	}
+	s.State = s.client.State(time.Now())
+	s.react(s.client.Events())
+	return nil
+}
+
+func (s *serverGame) Draw(screen *ebiten.Image) {
+	s.Game.Draw(screen)
+	status := ""
+	if s.err != nil {
+		status = "Lost connection to the server"
+	}
+	s.drawOnline(screen, s.client.Side, status)
+}
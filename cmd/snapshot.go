@@ -0,0 +1,75 @@
+package main
+
+import (
+	"image/color"
+	"log"
+	"time"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
+	"github.com/hajimehoshi/ebiten/text"
+
+	"github.com/fabianvf/pong-golang/pkg/snapshot"
+)
+
+// noticeDuration is how long messages such as "Saved" stay on screen.
+const noticeDuration = 2 * time.Second
+
+// updateSnapshots handles the quick save keys: F5 saves the match and F9
+// goes back to the last save. The save is kept in memory as well as on disk,
+// so it works where nothing can be written, like in the browser.
+func (g *Game) updateSnapshots() {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyF5):
+		g.quicksave = snapshot.New(g.State, g.Seed)
+		if g.snapshotPath != "" {
+			if err := snapshot.Save(g.snapshotPath, g.quicksave); err != nil {
+				log.Printf("saving snapshot: %v", err)
+				g.notify("Save failed")
+				return
+			}
+		}
+		g.notify("Saved")
+	case inpututil.IsKeyJustPressed(ebiten.KeyF9):
+		s := g.quicksave
+		if s == nil && g.snapshotPath != "" {
+			var err error
+			if s, err = snapshot.Load(g.snapshotPath); err != nil {
+				log.Printf("loading snapshot: %v", err)
+			}
+		}
+		if s == nil {
+			g.notify("Nothing to load")
+			return
+		}
+		g.restore(s)
+		g.notify("Loaded")
+	}
+}
+
+// restore puts the match back the way it was when s was taken. The
+// recording carries on from there, so it keeps the whole session.
+func (g *Game) restore(s *snapshot.Snapshot) {
+	g.State = s.State
+	g.Seed = s.Seed
+	// The snapshot may be of a match with a different number of players.
+	g.spinner = NewSpinner(&g.View, spinnerSensitivity, g.State.Config.Sides())
+	g.Controllers = g.withCPU(g.controllersFor(g.Bindings))
+	g.trails = NewTrails(&g.State.Balls)
+	if g.recorder != nil {
+		g.recorder.Jump(g.State)
+	}
+}
+
+func (g *Game) notify(message string) {
+	g.notice = message
+	g.noticeUntil = time.Now().Add(noticeDuration)
+}
+
+func (g *Game) drawNotice(screen *ebiten.Image) {
+	if g.notice == "" || time.Now().After(g.noticeUntil) {
+		return
+	}
+	x, _ := g.centerText(g.notice, smallArcadeFont)
+	text.Draw(screen, g.notice, smallArcadeFont, x, g.View.WindowHeight-smallFontSize, color.Black)
+}
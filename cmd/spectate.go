@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"image/color"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
+	"github.com/hajimehoshi/ebiten/text"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+	"github.com/fabianvf/pong-golang/pkg/spectate"
+)
+
+// startSpectating opens the match to spectators if -spectate-port is set.
+func startSpectating() (*spectate.Publisher, error) {
+	if *spectatePort == 0 {
+		return nil, nil
+	}
+	return spectate.Listen(net.JoinHostPort("", strconv.Itoa(*spectatePort)))
+}
+
+// spectatorGame draws a match streamed from another game. Nothing the
+// spectator does reaches the match.
+type spectatorGame struct {
+	*Game
+	viewer *spectate.Viewer
+}
+
+// runSpectate implements "pong spectate".
+func runSpectate(args []string) error {
+	fs := flag.NewFlagSet("spectate", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: pong spectate <host:port>")
+	}
+	v, err := spectate.Watch(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	loadResources()
+	ebiten.SetMaxTPS(*tps)
+	ebiten.SetWindowResizable(true)
+	ebiten.SetWindowTitle("Pong spectator")
+
+	g := NewGame(Options{Config: sim.DefaultConfig()})
+	g.watching = true
+	return ebiten.RunGame(&spectatorGame{Game: g, viewer: v})
+}
+
+func (s *spectatorGame) Update(screen *ebiten.Image) error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyF11) {
+		ebiten.SetFullscreen(!ebiten.IsFullscreen())
+	}
+	if state, ok := s.viewer.State(time.Now()); ok {
+		s.State = state
+	}
+	s.react(s.viewer.Events())
+	return nil
+}
+
+func (s *spectatorGame) Draw(screen *ebiten.Image) {
+	s.Game.Draw(screen)
+	if s.viewer.Err() != nil {
+		status := "The match has ended"
+		x, _ := s.centerText(status, smallArcadeFont)
+		text.Draw(screen, status, smallArcadeFont, x, s.View.WindowHeight-smallFontSize, color.Black)
+	}
+}
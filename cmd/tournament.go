@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"log"
+	"os"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/text"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+	"github.com/fabianvf/pong-golang/pkg/tournament"
+)
+
+// tournamentGame plays the matches of a tournament one after another on
+// the same window, saving after each one.
+type tournamentGame struct {
+	*Game
+	tournament *tournament.Tournament
+	path       string
+	match      *tournament.Match
+}
+
+// runTournament implements "pong tournament". Given player names it starts
+// a new tournament, otherwise it resumes the saved one. An unfinished
+// tournament in the default file is only replaced with -new.
+func runTournament(args []string) error {
+	fs := flag.NewFlagSet("tournament", flag.ExitOnError)
+	format := fs.String("format", string(tournament.SingleElimination), "single, double or round-robin")
+	file := fs.String("file", "", "file progress is saved to (default is pong/tournament.json in the user config directory)")
+	fresh := fs.Bool("new", false, "start a new tournament over an unfinished one in the default file")
+	fs.Parse(args)
+
+	path := *file
+	if path == "" {
+		var err error
+		if path, err = tournament.DefaultPath(); err != nil {
+			return err
+		}
+	}
+	config, err := oneOnOneConfig("tournament matches")
+	if err != nil {
+		return err
+	}
+
+	var t *tournament.Tournament
+	if fs.NArg() == 0 {
+		if t, err = tournament.Load(path); os.IsNotExist(err) {
+			return errors.New("usage: pong tournament [-format single|double|round-robin] [-new] <player> <player>...")
+		}
+		if err != nil {
+			return err
+		}
+		config.Rules = t.Rules
+	} else {
+		if config.Rules.Points == 0 {
+			return errors.New("tournament matches need -points to end")
+		}
+		t, err = tournament.New(tournament.Format(*format), fs.Args(), config.Rules)
+		if err != nil {
+			return err
+		}
+		if *file == "" && !*fresh {
+			if old, err := tournament.Load(path); err == nil && !old.Done() {
+				return errors.New("the saved tournament isn't finished: run pong tournament with no players to carry on, or add -new to start again")
+			}
+		}
+		if err := tournament.Save(path, t); err != nil {
+			return err
+		}
+	}
+
+	l, err := matchLevel()
+	if err != nil {
+		return err
+	}
+
+	b, bindingsPath := userBindings()
+	loadResources()
+	ebiten.SetMaxTPS(*tps)
+	ebiten.SetWindowResizable(true)
+	ebiten.SetWindowTitle("Pong tournament")
+
+	g := &tournamentGame{
+		Game: NewGame(Options{
+			Config:       config,
+			Bindings:     b,
+			BindingsPath: bindingsPath,
+			DeadZone:     *deadZone,
+			Pointer:      *pointer,
+			Level:        l.Name,
+		}),
+		tournament: t,
+		path:       path,
+	}
+	g.nextMatch()
+	return ebiten.RunGame(g)
+}
+
+// nextMatch sets up the next match, if there is one.
+func (t *tournamentGame) nextMatch() {
+	t.match = t.tournament.Next()
+	if t.match == nil {
+		return
+	}
+	t.State = sim.NewState(t.State.Config, matchSeed())
+	t.trails = NewTrails(&t.State.Balls)
+	t.Controllers = t.withCPU(t.controllersFor(t.Bindings))
+	for side, p := range t.match.Players {
+		t.names[side] = t.tournament.Players[p]
+	}
+	t.overPrompt = ""
+}
+
+func (t *tournamentGame) Update(screen *ebiten.Image) error {
+	if t.match == nil {
+		return nil
+	}
+	over := t.State.Mode == sim.ModeOver
+	if err := t.Game.Update(screen); err != nil {
+		return err
+	}
+	switch {
+	case !over && t.State.Mode == sim.ModeOver:
+		t.record()
+	case over && t.State.Mode != sim.ModeOver:
+		// Start was pressed for a rematch; it's someone else's turn.
+		t.nextMatch()
+	}
+	return nil
+}
+
+// record settles the match that just ended and saves the tournament.
+func (t *tournamentGame) record() {
+	if err := t.tournament.Record(t.match, t.State.Winner, [2]int{t.State.Stats.Points[sim.Left], t.State.Stats.Points[sim.Right]}); err != nil {
+		log.Printf("recording match: %v", err)
+		return
+	}
+	if err := tournament.Save(t.path, t.tournament); err != nil {
+		log.Printf("saving tournament: %v", err)
+		t.notify("Could not save the tournament")
+	}
+	t.overPrompt = "Press to Start the Next Match"
+	if t.tournament.Done() {
+		t.overPrompt = "Press to See the Results"
+	}
+}
+
+func (t *tournamentGame) Draw(screen *ebiten.Image) {
+	if t.match == nil {
+		t.drawResults(screen)
+		return
+	}
+	t.Game.Draw(screen)
+	if t.rebind != nil {
+		return
+	}
+	round := fmt.Sprintf("Round %d", t.match.Round)
+	if t.match.Bracket == tournament.BracketFinal {
+		round = "Final"
+	} else if t.match.Bracket != "" {
+		round += ", " + t.match.Bracket + " bracket"
+	}
+	players := t.names[sim.Left] + " vs " + t.names[sim.Right]
+	for i, line := range []string{round, players} {
+		x, _ := t.centerText(line, smallArcadeFont)
+		text.Draw(screen, line, smallArcadeFont, x, fontSize*2+smallFontSize*2*i, color.Black)
+	}
+}
+
+// drawResults shows the champion and the final standings.
+func (t *tournamentGame) drawResults(screen *ebiten.Image) {
+	t.drawBackground(screen)
+	champion, _ := t.tournament.Champion()
+	title := champion + " wins the tournament"
+	x, _ := t.centerText(title, arcadeFont)
+	text.Draw(screen, title, arcadeFont, x, fontSize*2, color.Black)
+	for i, s := range t.tournament.Standings() {
+		line := fmt.Sprintf("%d. %s  %d-%d  %+d", i+1, s.Name, s.Wins, s.Losses, s.Difference)
+		x, _ := t.centerText(line, smallArcadeFont)
+		text.Draw(screen, line, smallArcadeFont, x, fontSize*4+smallFontSize*2*i, color.Black)
+	}
+}
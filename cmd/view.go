@@ -0,0 +1,42 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// View maps the simulation's arena onto the window, keeping its aspect ratio
+// and centering it with letterboxing on whichever axis has room to spare.
+type View struct {
+	WindowWidth  int
+	WindowHeight int
+}
+
+func (v View) scale() float64 {
+	return math.Min(float64(v.WindowWidth)/sim.ArenaWidth, float64(v.WindowHeight)/sim.ArenaHeight)
+}
+
+func (v View) offset() (float64, float64) {
+	s := v.scale()
+	return (float64(v.WindowWidth) - sim.ArenaWidth*s) / 2, (float64(v.WindowHeight) - sim.ArenaHeight*s) / 2
+}
+
+// GeoM returns the transform from arena units to screen pixels.
+func (v View) GeoM() ebiten.GeoM {
+	var m ebiten.GeoM
+	s := v.scale()
+	m.Scale(s, s)
+	m.Translate(v.offset())
+	return m
+}
+
+// ToWorld converts a screen position, such as a cursor or touch, to arena
+// units.
+func (v View) ToWorld(x, y int) sim.Pair {
+	s := v.scale()
+	ox, oy := v.offset()
+	return sim.Pair{X: (float64(x) - ox) / s, Y: (float64(y) - oy) / s}
+}
@@ -0,0 +1,143 @@
+// Package bindings maps game actions to the keys and buttons that trigger
+// them, and reads and writes those mappings as JSON.
+package bindings
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type Action string
+
+const (
+	LeftUp    Action = "left_up"
+	LeftDown  Action = "left_down"
+	RightUp   Action = "right_up"
+	RightDown Action = "right_down"
+	// The top and bottom paddles only play in four player matches.
+	TopLeft     Action = "top_left"
+	TopRight    Action = "top_right"
+	BottomLeft  Action = "bottom_left"
+	BottomRight Action = "bottom_right"
+	Start       Action = "start"
+	Pause       Action = "pause"
+)
+
+// Actions lists every action in the order they are shown to players.
+var Actions = []Action{LeftUp, LeftDown, RightUp, RightDown, TopLeft, TopRight, BottomLeft, BottomRight, Start, Pause}
+
+// movement actions may not share inputs with each other: a key that moves
+// two paddles, or one paddle both ways, is always a mistake.
+var movement = map[Action]bool{
+	LeftUp: true, LeftDown: true, RightUp: true, RightDown: true,
+	TopLeft: true, TopRight: true, BottomLeft: true, BottomRight: true,
+}
+
+// Bindings maps each action to the names of the inputs that trigger it.
+type Bindings map[Action][]string
+
+func Default() Bindings {
+	return Bindings{
+		LeftUp:      {"W"},
+		LeftDown:    {"S"},
+		RightUp:     {"Up"},
+		RightDown:   {"Down"},
+		TopLeft:     {"C"},
+		TopRight:    {"V"},
+		BottomLeft:  {"N"},
+		BottomRight: {"M"},
+		Start:       {"Space", "Enter", "GamepadButton0"},
+		Pause:       {"Escape", "GamepadButton9"},
+	}
+}
+
+// Load reads bindings from a JSON file. Actions missing from the file keep
+// their default bindings.
+func Load(path string) (Bindings, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file Bindings
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	b := Default()
+	for action, inputs := range file {
+		b[action] = inputs
+	}
+	return b, nil
+}
+
+func (b Bindings) Save(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Validate checks that every action is known and bound, that every input
+// name is accepted by known, and that no input moves two ways at once. All
+// problems are reported together.
+func (b Bindings) Validate(known func(name string) bool) error {
+	var problems []string
+	valid := map[Action]bool{}
+	for _, action := range Actions {
+		valid[action] = true
+	}
+
+	var actions []string
+	for action := range b {
+		actions = append(actions, string(action))
+	}
+	sort.Strings(actions)
+	for _, a := range actions {
+		if !valid[Action(a)] {
+			problems = append(problems, fmt.Sprintf("unknown action %q", a))
+		}
+	}
+
+	usedBy := map[string]Action{}
+	for _, action := range Actions {
+		inputs := b[action]
+		if len(inputs) == 0 {
+			problems = append(problems, fmt.Sprintf("action %q has no inputs bound", action))
+		}
+		for _, input := range inputs {
+			if !known(input) {
+				problems = append(problems, fmt.Sprintf("action %q: unknown input %q", action, input))
+				continue
+			}
+			if !movement[action] {
+				continue
+			}
+			if other, ok := usedBy[input]; ok {
+				problems = append(problems, fmt.Sprintf("input %q is bound to both %q and %q", input, other, action))
+			}
+			usedBy[input] = action
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid bindings:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// DefaultPath is where bindings are kept when no path is given.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pong", "bindings.json"), nil
+}
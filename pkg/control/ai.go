@@ -0,0 +1,203 @@
+package control
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// serving reports whether side is holding the ball for a manual serve, which
+// computer players take straight away.
+func serving(s *sim.State, side sim.Side) bool {
+	return s.Mode == sim.ModeServe && s.Config.Serve.Manual && s.Server == side
+}
+
+// serve presses start, letting go first if it is already held.
+func serve(s *sim.State, side sim.Side) sim.Intent {
+	if s.Held[side]&sim.ButtonStart != 0 {
+		return sim.Intent{}
+	}
+	return sim.Intent{Buttons: sim.ButtonStart}
+}
+
+// Difficulty describes how well the computer player plays.
+type Difficulty struct {
+	Name string
+	// ReactionDelay is how many seconds old the ball position the computer
+	// acts on is.
+	ReactionDelay float64
+	// TrackingError is the largest distance, in arena units, the computer
+	// misjudges where it should meet the ball by. A new error is picked
+	// every time the ball changes direction.
+	TrackingError float64
+	// MaxSpeed is the fraction of full paddle speed the computer will use.
+	MaxSpeed float64
+	// PredictBounces makes the computer work out where the ball will end up
+	// after bouncing off the walls, rather than just following it.
+	PredictBounces bool
+}
+
+var (
+	Easy = Difficulty{
+		Name:          "easy",
+		ReactionDelay: 0.2,
+		TrackingError: sim.ArenaHeight / 10,
+		MaxSpeed:      0.75,
+	}
+	Normal = Difficulty{
+		Name:          "normal",
+		ReactionDelay: 0.15,
+		TrackingError: sim.ArenaHeight / 14,
+		MaxSpeed:      0.8,
+	}
+	Hard = Difficulty{
+		Name:           "hard",
+		ReactionDelay:  0.08,
+		TrackingError:  sim.ArenaHeight / 25,
+		MaxSpeed:       1,
+		PredictBounces: true,
+	}
+	Impossible = Difficulty{
+		Name:           "impossible",
+		MaxSpeed:       1,
+		PredictBounces: true,
+	}
+)
+
+// Difficulties lists the presets from easiest to hardest.
+var Difficulties = []Difficulty{Easy, Normal, Hard, Impossible}
+
+func DifficultyByName(name string) (Difficulty, bool) {
+	for _, d := range Difficulties {
+		if strings.EqualFold(d.Name, name) {
+			return d, true
+		}
+	}
+	return Difficulty{}, false
+}
+
+// AI is a computer player. It keeps a short history of the balls so it can
+// act on what it saw ReactionDelay ago, like a human would. With several
+// balls in play it goes for whichever will reach it first.
+type AI struct {
+	Difficulty Difficulty
+
+	rand    *rand.Rand
+	history [][sim.MaxBalls]sim.Ball
+	aim     float64
+	lastVX  float64
+}
+
+func NewAI(d Difficulty, seed int64) *AI {
+	return &AI{Difficulty: d, rand: rand.New(rand.NewSource(seed))}
+}
+
+func (a *AI) Intent(s *sim.State, side sim.Side) sim.Intent {
+	if serving(s, side) {
+		return serve(s, side)
+	}
+	if s.Mode != sim.ModePlay {
+		a.history = a.history[:0]
+		return sim.Intent{}
+	}
+
+	delay := int(a.Difficulty.ReactionDelay * sim.TickRate)
+	a.history = append(a.history, s.Balls)
+	if len(a.history) > delay+1 {
+		a.history = a.history[len(a.history)-delay-1:]
+	}
+	balls := a.history[0]
+
+	// The ball only bounces off the walls across its path if they are
+	// solid; in a four player match they are other players' goals.
+	walls := !s.InPlay(sim.Top) && !s.InPlay(sim.Bottom)
+	if side.Horizontal() {
+		walls = !s.InPlay(sim.Left) && !s.InPlay(sim.Right)
+	}
+
+	// Horizontal paddles see the arena transposed, which makes them the
+	// same as the left or right paddle.
+	paddle, height := *s.Paddle(side), sim.ArenaHeight
+	if side.Horizontal() {
+		paddle.Rect, height = paddle.Transposed(), sim.ArenaWidth
+		for i := range balls {
+			balls[i] = balls[i].Transposed()
+		}
+		if side == sim.Top {
+			side = sim.Left
+		} else {
+			side = sim.Right
+		}
+	}
+	ball := pickBall(&balls, &paddle, side)
+
+	if math.Signbit(ball.Velocity.X) != math.Signbit(a.lastVX) {
+		a.aim = (a.rand.Float64()*2 - 1) * a.Difficulty.TrackingError
+	}
+	a.lastVX = ball.Velocity.X
+
+	target := height / 2
+	if approaching(&ball, side) {
+		target = a.intercept(&ball, &paddle, side, height, walls) + a.aim
+	}
+
+	// Slow down near the target instead of overshooting back and forth.
+	diff := target - paddle.Center().Y
+	axis := diff / (s.Config.PaddleSpeed * sim.Dt * 4)
+	axis = math.Max(-1, math.Min(1, axis)) * a.Difficulty.MaxSpeed
+	return sim.Intent{Axis: axis}
+}
+
+// pickBall is the ball that will reach the paddle first, or if none are on
+// their way, any ball in play.
+func pickBall(balls *[sim.MaxBalls]sim.Ball, paddle *sim.Paddle, side sim.Side) sim.Ball {
+	var best sim.Ball
+	soonest := math.Inf(1)
+	for _, b := range balls {
+		if !b.Live {
+			continue
+		}
+		if !best.Live {
+			best = b
+		}
+		if !approaching(&b, side) {
+			continue
+		}
+		if t := (paddle.Center().X - b.Coord.X) / b.Velocity.X; t < soonest {
+			best, soonest = b, t
+		}
+	}
+	return best
+}
+
+func approaching(ball *sim.Ball, side sim.Side) bool {
+	if side == sim.Left {
+		return ball.Velocity.X < 0
+	}
+	return ball.Velocity.X > 0
+}
+
+// intercept estimates the height at which the ball will reach the paddle.
+// Without walls to bounce off, the ball either gets there in a straight line
+// or goes out first, so the paddle heads for the end it leaves by.
+func (a *AI) intercept(ball *sim.Ball, paddle *sim.Paddle, side sim.Side, height float64, walls bool) float64 {
+	x := paddle.X + paddle.W + ball.Radius
+	if side == sim.Right {
+		x = paddle.X - ball.Radius
+	}
+	if !a.Difficulty.PredictBounces {
+		return ball.Coord.Y
+	}
+	if !walls {
+		t := (x - ball.Coord.X) / ball.Velocity.X
+		return math.Max(0, math.Min(height, ball.Coord.Y+ball.Velocity.Y*t))
+	}
+
+	intercept, ok := sim.PredictIntercept(*ball, x, height)
+	if !ok {
+		return ball.Coord.Y
+	}
+	return intercept.Y
+}
@@ -0,0 +1,33 @@
+package control
+
+import (
+	"testing"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// TestAIWithoutWalls checks the computer doesn't expect a ball to bounce off
+// a wall that is really a goal. The ball below heads up and left; with two
+// players it bounces off the top wall and comes down to the lower half of
+// the left paddle, but with four it leaves through the top goal first.
+func TestAIWithoutWalls(t *testing.T) {
+	for _, tc := range []struct {
+		players int
+		down    bool
+	}{
+		{players: 2, down: true},
+		{players: 4, down: false},
+	} {
+		config := sim.DefaultConfig()
+		config.Players = tc.players
+		s := sim.NewState(config, 1)
+		s.Mode = sim.ModePlay
+		s.Balls[0].Coord = sim.Pair{X: 2, Y: 0.3}
+		s.Balls[0].Velocity = sim.Pair{X: -1, Y: -1.6}
+
+		intent := NewAI(Impossible, 1).Intent(&s, sim.Left)
+		if down := intent.Axis > 0; down != tc.down {
+			t.Errorf("%d players: axis %v, want down %v", tc.players, intent.Axis, tc.down)
+		}
+	}
+}
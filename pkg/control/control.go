@@ -0,0 +1,46 @@
+// Package control turns input sources into per-tick paddle intents for the
+// simulation. Sources that need a window, like the keyboard, live with the
+// renderer; the ones here only look at the simulation state.
+package control
+
+import (
+	"math"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// Controller drives one paddle. Intent is called once per simulation step
+// with the state about to be stepped.
+type Controller interface {
+	Intent(s *sim.State, side sim.Side) sim.Intent
+}
+
+// Multi lets several sources drive the same paddle, e.g. keyboard and touch.
+// Axes are summed and clamped, buttons are combined. The first source asking
+// to track a position gets its way unless another one is moving the paddle
+// directly.
+type Multi []Controller
+
+func (m Multi) Intent(s *sim.State, side sim.Side) sim.Intent {
+	var out sim.Intent
+	for _, c := range m {
+		in := c.Intent(s, side)
+		out.Axis += in.Axis
+		out.Buttons |= in.Buttons
+		if in.Track && !out.Track {
+			out.Track, out.Target = true, in.Target
+		}
+	}
+	out.Axis = math.Max(-1, math.Min(1, out.Axis))
+	if out.Axis != 0 {
+		out.Track = false
+	}
+	return out
+}
+
+// Idle never moves.
+type Idle struct{}
+
+func (Idle) Intent(s *sim.State, side sim.Side) sim.Intent {
+	return sim.Intent{}
+}
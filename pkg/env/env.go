@@ -0,0 +1,142 @@
+// Package env wraps the simulation as a reinforcement learning environment:
+// an agent controls one paddle, gets an observation vector back after every
+// action and is rewarded for winning points.
+package env
+
+import (
+	"github.com/fabianvf/pong-golang/pkg/control"
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// ObservationNames describes each element of an observation vector. All
+// positions and speeds are in arena units, from the agent's point of view
+// only in that "own" and "opponent" follow the agent's side. With several
+// balls in play the ball is the live one nearest the agent's goal.
+var ObservationNames = []string{
+	"ball_x",
+	"ball_y",
+	"ball_vx",
+	"ball_vy",
+	"own_paddle_y",
+	"opponent_paddle_y",
+	"own_score",
+	"opponent_score",
+}
+
+type Options struct {
+	Config sim.Config
+	// Side is the paddle the agent controls.
+	Side sim.Side
+	// Opponent builds the controller for the other paddle on every reset.
+	// A nil Opponent leaves the other paddle idle.
+	Opponent func(seed int64) control.Controller
+	// FrameSkip is how many simulation steps each action is repeated for.
+	FrameSkip int
+	// Points is how many points end an episode.
+	Points int
+	// Seed is where the seeds of episodes reset without one of their own
+	// start counting up from.
+	Seed int64
+}
+
+type Env struct {
+	Options
+	State    sim.State
+	opponent control.Controller
+	points   int
+	episodes int64
+}
+
+func New(opts Options) *Env {
+	if opts.FrameSkip < 1 {
+		opts.FrameSkip = 1
+	}
+	if opts.Points < 1 {
+		opts.Points = 1
+	}
+	// Points decides when an episode is over. A match that ended by its
+	// own rules would start again and wipe the score part way through.
+	opts.Config.Rules = sim.Rules{}
+	e := &Env{Options: opts}
+	e.Reset(0)
+	return e
+}
+
+// Reset starts a new episode with the ball in play. A seed of 0 takes the
+// next one counting up from Seed, so every episode plays out differently.
+func (e *Env) Reset(seed int64) []float64 {
+	if seed == 0 {
+		e.episodes++
+		seed = e.Seed + e.episodes
+	}
+	e.State = sim.NewState(e.Config, seed)
+	e.opponent = control.Idle{}
+	if e.Opponent != nil {
+		e.opponent = e.Opponent(seed)
+	}
+	e.points = 0
+	e.serve()
+	return e.Observe()
+}
+
+func (e *Env) other() sim.Side {
+	return 1 - e.Side
+}
+
+func (e *Env) serve() {
+	var in sim.Inputs
+	in[e.Side].Buttons = sim.ButtonStart
+	e.State, _ = sim.Step(e.State, in)
+}
+
+// Step applies action, the agent's paddle axis from -1 (up) to 1 (down), for
+// FrameSkip simulation steps. If the ball isn't in play the first of them
+// also serves it. The reward is +1 for every point the agent wins and -1
+// for every point it loses.
+func (e *Env) Step(action float64) (obs []float64, reward float64, done bool) {
+	for i := 0; i < e.FrameSkip && !done; i++ {
+		var in sim.Inputs
+		in[e.Side] = sim.Intent{Axis: action}
+		if i == 0 && e.State.Mode != sim.ModePlay {
+			in[e.Side].Buttons = sim.ButtonStart
+		}
+		in[e.other()] = e.opponent.Intent(&e.State, e.other())
+
+		var events []sim.Event
+		e.State, events = sim.Step(e.State, in)
+		for _, ev := range events {
+			if ev.Kind != sim.EventScore {
+				continue
+			}
+			if ev.Side == e.Side {
+				reward++
+			} else {
+				reward--
+			}
+			e.points++
+			done = e.points >= e.Points
+		}
+	}
+	return e.Observe(), reward, done
+}
+
+func (e *Env) Observe() []float64 {
+	s := &e.State
+	ball := &s.Balls[0]
+	for i := range s.Balls {
+		b := &s.Balls[i]
+		if b.Live && (!ball.Live || sim.WallDistance(e.Side, b.Coord) < sim.WallDistance(e.Side, ball.Coord)) {
+			ball = b
+		}
+	}
+	return []float64{
+		ball.Coord.X,
+		ball.Coord.Y,
+		ball.Velocity.X,
+		ball.Velocity.Y,
+		s.Paddle(e.Side).Center().Y,
+		s.Paddle(e.other()).Center().Y,
+		float64(s.Score[e.Side]),
+		float64(s.Score[e.other()]),
+	}
+}
@@ -0,0 +1,81 @@
+package env
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+func TestEpisodeOutlastsMatch(t *testing.T) {
+	config := sim.DefaultConfig()
+	config.Serve.Countdown = 0
+	e := New(Options{Config: config, Points: config.Rules.Points * 3, FrameSkip: 4})
+	total := 0
+	for steps := 0; ; steps++ {
+		if steps > 100000 {
+			t.Fatal("episode never ended")
+		}
+		_, _, done := e.Step(0)
+		score := e.State.Score[sim.Left] + e.State.Score[sim.Right]
+		if score < total {
+			t.Fatalf("score went from %d to %d", total, score)
+		}
+		total = score
+		if done {
+			break
+		}
+	}
+	if total != e.Points {
+		t.Errorf("episode ended on %d points, want %d", total, e.Points)
+	}
+}
+
+func TestResetSeeds(t *testing.T) {
+	// Each step runs past the serve countdown, so the ball is on its way.
+	e := New(Options{Config: sim.DefaultConfig(), FrameSkip: sim.TickRate + 10})
+	launch := func(seed int64) []float64 {
+		e.Reset(seed)
+		obs, _, _ := e.Step(0)
+		return obs
+	}
+	first, second := launch(0), launch(0)
+	if reflect.DeepEqual(first, second) {
+		t.Errorf("episodes without a seed both start with %v", first)
+	}
+	if a, b := launch(5), launch(5); !reflect.DeepEqual(a, b) {
+		t.Errorf("the same seed starts differently: %v and %v", a, b)
+	}
+}
+
+func TestObserveNearestBall(t *testing.T) {
+	e := New(Options{Config: sim.DefaultConfig(), Side: sim.Right})
+	s := &e.State
+	s.Balls[0] = sim.Ball{Live: true, Coord: sim.Pair{X: 1, Y: 1}}
+	s.Balls[1] = sim.Ball{Live: true, Coord: sim.Pair{X: 3, Y: 2}}
+	if obs := e.Observe(); obs[0] != 3 || obs[1] != 2 {
+		t.Errorf("observed the ball at %g,%g, want the one nearest the right", obs[0], obs[1])
+	}
+}
+
+func TestServeTicks(t *testing.T) {
+	e := New(Options{Config: sim.DefaultConfig(), FrameSkip: 4})
+	if e.State.Mode != sim.ModeServe {
+		t.Fatalf("reset left the match in mode %d", e.State.Mode)
+	}
+	countdown := e.State.Countdown
+	for i := 1; i <= 3; i++ {
+		e.Step(0)
+		if got, want := e.State.Countdown, countdown-i*e.FrameSkip; got != want {
+			t.Fatalf("countdown %d after %d steps, want %d", got, i, want)
+		}
+	}
+
+	// After a point the next step serves again, within its frame skip.
+	e.State.Mode = sim.ModeWait
+	e.Step(0)
+	want := int(e.Config.Serve.Countdown*sim.TickRate) - (e.FrameSkip - 1)
+	if e.State.Mode != sim.ModeServe || e.State.Countdown != want {
+		t.Errorf("mode %d with countdown %d after serving, want countdown %d", e.State.Mode, e.State.Countdown, want)
+	}
+}
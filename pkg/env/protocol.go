@@ -0,0 +1,113 @@
+package env
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// Request is one line sent to the environment. Cmd is one of "spec",
+// "reset", "step", "observe" or "close". A reset without a Seed gets a new
+// one from the environment.
+type Request struct {
+	Cmd    string  `json:"cmd"`
+	Action float64 `json:"action,omitempty"`
+	Seed   int64   `json:"seed,omitempty"`
+}
+
+// Response is the line sent back for every request.
+type Response struct {
+	Observation []float64 `json:"obs,omitempty"`
+	Reward      float64   `json:"reward"`
+	Done        bool      `json:"done"`
+	Info        *Info     `json:"info,omitempty"`
+	Spec        *Spec     `json:"spec,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+type Info struct {
+	Score [2]int `json:"score"`
+}
+
+// Spec tells an agent what to expect before it starts.
+type Spec struct {
+	ObservationNames []string `json:"obs_names"`
+	ActionLow        float64  `json:"action_low"`
+	ActionHigh       float64  `json:"action_high"`
+	FrameSkip        int      `json:"frame_skip"`
+	TickRate         int      `json:"tick_rate"`
+}
+
+// Serve speaks the line-delimited JSON protocol over r and w until the
+// client sends "close" or hangs up.
+func (e *Env) Serve(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	encoder := json.NewEncoder(w)
+	for scanner.Scan() {
+		var req Request
+		var resp Response
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = fmt.Sprintf("bad request: %v", err)
+			if err := encoder.Encode(resp); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch req.Cmd {
+		case "spec":
+			resp.Spec = &Spec{
+				ObservationNames: ObservationNames,
+				ActionLow:        -1,
+				ActionHigh:       1,
+				FrameSkip:        e.FrameSkip,
+				TickRate:         sim.TickRate,
+			}
+		case "reset":
+			resp.Observation = e.Reset(req.Seed)
+		case "step":
+			resp.Observation, resp.Reward, resp.Done = e.Step(req.Action)
+		case "observe":
+			resp.Observation = e.Observe()
+		case "close":
+			return nil
+		default:
+			resp.Error = fmt.Sprintf("unknown command %q", req.Cmd)
+		}
+		if resp.Observation != nil {
+			resp.Info = &Info{Score: [2]int{e.State.Score[sim.Left], e.State.Score[sim.Right]}}
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// ListenAndServe accepts connections on addr and gives each one its own
+// environment from newEnv.
+func ListenAndServe(addr string, newEnv func() *Env) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	log.Printf("environment listening on %s", l.Addr())
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := newEnv().Serve(conn, conn); err != nil {
+				log.Printf("%s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,41 @@
+package level
+
+import (
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// Builtin are the levels that come with the game, Classic first.
+var Builtin = []Level{
+	{Name: "Classic"},
+	{
+		Name: "Pillars",
+		Obstacles: []Obstacle{
+			{Type: Rect, X: 1.9, Y: 0.5, W: 0.2, H: 0.6},
+			{Type: Rect, X: 1.9, Y: 1.9, W: 0.2, H: 0.6},
+		},
+	},
+	{
+		Name: "Bumpers",
+		Obstacles: []Obstacle{
+			{Type: Bumper, X: 1.3, Y: 0.9, Radius: 0.12},
+			{Type: Bumper, X: 2.7, Y: 0.9, Radius: 0.12},
+			{Type: Bumper, X: 1.3, Y: 2.1, Radius: 0.12},
+			{Type: Bumper, X: 2.7, Y: 2.1, Radius: 0.12},
+		},
+	},
+	{
+		Name: "Sweeper",
+		Obstacles: []Obstacle{
+			{Type: Rect, X: 1.95, Y: 0.6, W: 0.1, H: 0.4, Move: sim.Pair{Y: 1.4}, Period: 4},
+		},
+		Spawns: []sim.Pair{{X: 1.5, Y: 1.5}, {X: 2.5, Y: 1.5}},
+	},
+	{
+		Name:  "Narrow",
+		Goals: map[string]float64{"left": 0.5, "right": 0.5, "top": 0.5, "bottom": 0.5},
+		Obstacles: []Obstacle{
+			{Type: Circle, X: 2, Y: 1.5, Radius: 0.25},
+		},
+		Spawns: []sim.Pair{{X: 2, Y: 0.9}, {X: 2, Y: 2.1}},
+	},
+}
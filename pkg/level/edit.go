@@ -0,0 +1,342 @@
+package level
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+const (
+	// Grid is the spacing the editor snaps things to, in arena units.
+	Grid = sim.ArenaWidth / 40
+	// HandleSize is how close a click must be to grab a resize handle.
+	HandleSize = sim.ArenaWidth / 60
+	// SpawnSize is the radius of a spawn point's marker.
+	SpawnSize = sim.ArenaWidth / 100
+	// SpawnTool places spawn points rather than obstacles.
+	SpawnTool = "spawn"
+	// editPeriod is the round trip time given to an obstacle when it is
+	// first made to move.
+	editPeriod = 4
+)
+
+// Tools are what the editor can place, in the order they are listed.
+var Tools = []string{Rect, Circle, Bumper, SpawnTool}
+
+var goalNames = [sim.MaxSides]string{
+	sim.Left:   "left",
+	sim.Right:  "right",
+	sim.Top:    "top",
+	sim.Bottom: "bottom",
+}
+
+// Item is an obstacle or, if Spawn is set, a spawn point. An Index of -1 is
+// nothing.
+type Item struct {
+	Spawn bool
+	Index int
+}
+
+var NoItem = Item{Index: -1}
+
+const (
+	dragNone = iota
+	dragMove
+	dragResize
+	dragTravel
+)
+
+// Editor makes changes to a level the way the level editor's mouse and
+// keys do, with positions in arena units.
+type Editor struct {
+	Level    Level
+	Tool     string
+	Snap     bool
+	Selected Item
+	// Changed is set by every edit. The caller clears it once saved.
+	Changed bool
+
+	drag int
+	// grab is where the drag started, relative to the item's position, or
+	// its travel for dragTravel.
+	grab sim.Pair
+}
+
+func NewEditor(l Level) *Editor {
+	return &Editor{Level: l, Tool: Rect, Snap: true, Selected: NoItem}
+}
+
+// Press starts dragging whatever is under the cursor, placing something
+// new if there is nothing. With shift held, pressing on the selected
+// obstacle or its handle drags out how far it travels instead.
+func (e *Editor) Press(cursor sim.Pair, shift bool) error {
+	if o := e.Obstacle(); o != nil {
+		h := Handle(o)
+		onHandle := math.Hypot(cursor.X-h.X, cursor.Y-h.Y) < HandleSize
+		if shift && (onHandle || e.ItemAt(cursor) == e.Selected) {
+			e.drag = dragTravel
+			e.grab = sim.Pair{X: cursor.X - o.Move.X, Y: cursor.Y - o.Move.Y}
+			return nil
+		}
+		if onHandle {
+			e.drag = dragResize
+			return nil
+		}
+	}
+	e.Selected = e.ItemAt(cursor)
+	if e.Selected == NoItem {
+		if err := e.Place(cursor); err != nil {
+			return err
+		}
+	}
+	x, y := e.Position(e.Selected)
+	e.drag = dragMove
+	e.grab = sim.Pair{X: cursor.X - x, Y: cursor.Y - y}
+	return nil
+}
+
+// DragTo carries on the drag Press started.
+func (e *Editor) DragTo(cursor sim.Pair) {
+	if e.drag == dragNone || e.Selected == NoItem {
+		return
+	}
+	var was Obstacle
+	if o := e.Obstacle(); o != nil {
+		was = *o
+	}
+	wasX, wasY := e.Position(e.Selected)
+	switch e.drag {
+	case dragMove:
+		x, y := e.snapTo(cursor.X-e.grab.X), e.snapTo(cursor.Y-e.grab.Y)
+		if e.Selected.Spawn {
+			e.Level.Spawns[e.Selected.Index] = sim.Pair{X: x, Y: y}
+		} else {
+			o := e.Obstacle()
+			o.X, o.Y = x, y
+		}
+	case dragResize:
+		o := e.Obstacle()
+		if o.Type == Rect {
+			o.W = math.Max(Grid, e.snapTo(cursor.X-o.X))
+			o.H = math.Max(Grid, e.snapTo(cursor.Y-o.Y))
+		} else {
+			o.Radius = math.Max(Grid, e.snapTo(math.Hypot(cursor.X-o.X, cursor.Y-o.Y)))
+		}
+	case dragTravel:
+		o := e.Obstacle()
+		o.Move = sim.Pair{X: e.snapTo(cursor.X - e.grab.X), Y: e.snapTo(cursor.Y - e.grab.Y)}
+		switch {
+		case o.Move == sim.Pair{}:
+			o.Period = 0
+		case o.Period == 0:
+			o.Period = editPeriod
+		}
+	}
+	x, y := e.Position(e.Selected)
+	if o := e.Obstacle(); (o != nil && *o != was) || x != wasX || y != wasY {
+		e.Changed = true
+	}
+}
+
+// Release ends a drag.
+func (e *Editor) Release() {
+	e.drag = dragNone
+}
+
+func (e *Editor) Dragging() bool {
+	return e.drag != dragNone
+}
+
+// Place puts something new from the current tool at the cursor and selects
+// it.
+func (e *Editor) Place(cursor sim.Pair) error {
+	x, y := e.snapTo(cursor.X), e.snapTo(cursor.Y)
+	if e.Tool == SpawnTool {
+		if len(e.Level.Spawns) >= sim.MaxSpawns {
+			return fmt.Errorf("At most %d spawn points", sim.MaxSpawns)
+		}
+		e.Level.Spawns = append(e.Level.Spawns, sim.Pair{X: x, Y: y})
+		e.Selected = Item{Spawn: true, Index: len(e.Level.Spawns) - 1}
+		e.Changed = true
+		return nil
+	}
+	if len(e.Level.Obstacles) >= sim.MaxObstacles {
+		return fmt.Errorf("At most %d obstacles", sim.MaxObstacles)
+	}
+	o := Obstacle{Type: e.Tool, X: x, Y: y}
+	switch e.Tool {
+	case Rect:
+		o.W, o.H = Grid*4, Grid*8
+		o.X, o.Y = e.snapTo(x-o.W/2), e.snapTo(y-o.H/2)
+	case Circle:
+		o.Radius = Grid * 3
+	case Bumper:
+		o.Radius = Grid * 2
+	}
+	e.Level.Obstacles = append(e.Level.Obstacles, o)
+	e.Selected = Item{Index: len(e.Level.Obstacles) - 1}
+	e.Changed = true
+	return nil
+}
+
+func (e *Editor) Remove(item Item) {
+	if item == NoItem {
+		return
+	}
+	if item.Spawn {
+		e.Level.Spawns = append(e.Level.Spawns[:item.Index], e.Level.Spawns[item.Index+1:]...)
+	} else {
+		e.Level.Obstacles = append(e.Level.Obstacles[:item.Index], e.Level.Obstacles[item.Index+1:]...)
+	}
+	e.Selected = NoItem
+	e.drag = dragNone
+	e.Changed = true
+}
+
+// ItemAt is the spawn point or obstacle under the cursor, picking the one
+// on top where they overlap.
+func (e *Editor) ItemAt(cursor sim.Pair) Item {
+	for i, p := range e.Level.Spawns {
+		if math.Hypot(cursor.X-p.X, cursor.Y-p.Y) < SpawnSize*2 {
+			return Item{Spawn: true, Index: i}
+		}
+	}
+	for i := len(e.Level.Obstacles) - 1; i >= 0; i-- {
+		built, _ := e.Level.Obstacles[i].Build()
+		if built.Shape != sim.ShapeNone && built.Overlaps(cursor, SpawnSize) {
+			return Item{Index: i}
+		}
+	}
+	return NoItem
+}
+
+// Obstacle is the selected obstacle, or nil if none is.
+func (e *Editor) Obstacle() *Obstacle {
+	if e.Selected == NoItem || e.Selected.Spawn {
+		return nil
+	}
+	return &e.Level.Obstacles[e.Selected.Index]
+}
+
+func (e *Editor) Position(item Item) (float64, float64) {
+	if item.Spawn {
+		p := e.Level.Spawns[item.Index]
+		return p.X, p.Y
+	}
+	o := e.Level.Obstacles[item.Index]
+	return o.X, o.Y
+}
+
+// Handle is where an obstacle is grabbed to resize it: a rectangle's bottom
+// right corner, or the right edge of a circle.
+func Handle(o *Obstacle) sim.Pair {
+	if o.Type == Rect {
+		return sim.Pair{X: o.X + o.W, Y: o.Y + o.H}
+	}
+	return sim.Pair{X: o.X + o.Radius, Y: o.Y}
+}
+
+func (e *Editor) snapTo(v float64) float64 {
+	if !e.Snap {
+		return v
+	}
+	return math.Round(v/Grid) * Grid
+}
+
+// ChangeType turns the selected obstacle into the next type, keeping it
+// about the same size and in the same place.
+func (e *Editor) ChangeType() {
+	o := e.Obstacle()
+	if o == nil {
+		return
+	}
+	switch o.Type {
+	case Rect:
+		o.Radius = math.Min(o.W, o.H) / 2
+		o.X, o.Y = o.X+o.W/2, o.Y+o.H/2
+		o.W, o.H = 0, 0
+		o.Type = Circle
+	case Circle:
+		o.Type = Bumper
+	default:
+		o.X, o.Y = o.X-o.Radius, o.Y-o.Radius
+		o.W, o.H = 2*o.Radius, 2*o.Radius
+		o.Radius = 0
+		o.Type = Rect
+	}
+	o.Restitution = 0
+	e.Changed = true
+}
+
+// Bounce changes the selected obstacle's restitution by step, leaving it
+// out of the file when it is back to the default.
+func (e *Editor) Bounce(step float64) {
+	o := e.Obstacle()
+	if o == nil {
+		return
+	}
+	standard := 1.0
+	if o.Type == Bumper {
+		standard = DefaultBumper
+	}
+	r := o.Restitution
+	if r == 0 {
+		r = standard
+	}
+	r = math.Round((r+step)*10) / 10
+	r = math.Max(MinRestitution, math.Min(r, MaxRestitution))
+	if r == standard {
+		r = 0
+	}
+	o.Restitution = r
+	e.Changed = true
+}
+
+// Period changes how long the selected obstacle takes to travel and back.
+func (e *Editor) Period(step float64) error {
+	o := e.Obstacle()
+	if o == nil {
+		return nil
+	}
+	if o.Period == 0 {
+		return errors.New("Shift drag to make it move first")
+	}
+	o.Period = math.Max(0.5, o.Period+step)
+	e.Changed = true
+	return nil
+}
+
+// ResizeGoal widens or narrows the goal on the wall nearest the cursor, if
+// it is by one.
+func (e *Editor) ResizeGoal(cursor sim.Pair, dy float64) {
+	side, nearest := sim.Side(-1), Margin
+	for s := sim.Side(0); s < sim.MaxSides; s++ {
+		if d := sim.WallDistance(s, cursor); d >= 0 && d < nearest {
+			side, nearest = s, d
+		}
+	}
+	if side < 0 {
+		return
+	}
+	name := goalNames[side]
+	size, ok := e.Level.Goals[name]
+	if !ok {
+		size = 1
+	}
+	step := 0.1
+	if dy < 0 {
+		step = -step
+	}
+	size = math.Max(0.1, math.Min(math.Round((size+step)*10)/10, 1))
+	if e.Level.Goals == nil {
+		e.Level.Goals = map[string]float64{}
+	}
+	if size == 1 {
+		delete(e.Level.Goals, name)
+	} else {
+		e.Level.Goals[name] = size
+	}
+	e.Changed = true
+}
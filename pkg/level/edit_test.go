@@ -0,0 +1,198 @@
+package level
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func sameObstacle(a, b Obstacle) bool {
+	return a.Type == b.Type && near(a.X, b.X) && near(a.Y, b.Y) && near(a.W, b.W) && near(a.H, b.H) &&
+		near(a.Radius, b.Radius) && near(a.Move.X, b.Move.X) && near(a.Move.Y, b.Move.Y) && a.Period == b.Period
+}
+
+func TestPlace(t *testing.T) {
+	cases := []struct {
+		tool string
+		at   sim.Pair
+		want Obstacle
+	}{
+		{Rect, sim.Pair{X: 1.03, Y: 1.52}, Obstacle{Type: Rect, X: 0.8, Y: 1.1, W: 0.4, H: 0.8}},
+		{Circle, sim.Pair{X: 2.01, Y: 1.48}, Obstacle{Type: Circle, X: 2, Y: 1.5, Radius: 0.3}},
+		{Bumper, sim.Pair{X: 3, Y: 2}, Obstacle{Type: Bumper, X: 3, Y: 2, Radius: 0.2}},
+	}
+	for _, c := range cases {
+		t.Run(c.tool, func(t *testing.T) {
+			e := NewEditor(Level{})
+			e.Tool = c.tool
+			if err := e.Press(c.at, false); err != nil {
+				t.Fatal(err)
+			}
+			if len(e.Level.Obstacles) != 1 || !sameObstacle(e.Level.Obstacles[0], c.want) {
+				t.Fatalf("placed %+v, want %+v", e.Level.Obstacles, c.want)
+			}
+			if e.Selected != (Item{Index: 0}) || !e.Changed || !e.Dragging() {
+				t.Errorf("selected %+v, changed %v, dragging %v", e.Selected, e.Changed, e.Dragging())
+			}
+		})
+	}
+
+	t.Run("spawn", func(t *testing.T) {
+		e := NewEditor(Level{})
+		e.Tool = SpawnTool
+		for i := 0; i < sim.MaxSpawns; i++ {
+			if err := e.Place(sim.Pair{X: 1 + 0.5*float64(i), Y: 1}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if e.Selected != (Item{Spawn: true, Index: sim.MaxSpawns - 1}) {
+			t.Errorf("selected %+v", e.Selected)
+		}
+		e.Changed = false
+		if err := e.Place(sim.Pair{X: 2, Y: 2}); err == nil {
+			t.Error("placed more spawn points than fit")
+		}
+		if len(e.Level.Spawns) != sim.MaxSpawns || e.Changed {
+			t.Errorf("%d spawn points, changed %v", len(e.Level.Spawns), e.Changed)
+		}
+	})
+
+	t.Run("too many obstacles", func(t *testing.T) {
+		e := NewEditor(Level{Obstacles: make([]Obstacle, sim.MaxObstacles)})
+		if err := e.Place(sim.Pair{X: 2, Y: 1.5}); err == nil || len(e.Level.Obstacles) != sim.MaxObstacles {
+			t.Errorf("placed obstacle %d", len(e.Level.Obstacles))
+		}
+	})
+}
+
+func TestRemove(t *testing.T) {
+	e := NewEditor(Level{
+		Obstacles: []Obstacle{
+			{Type: Circle, X: 1, Y: 1, Radius: 0.2},
+			{Type: Circle, X: 3, Y: 2, Radius: 0.2},
+		},
+		Spawns: []sim.Pair{{X: 2, Y: 1.5}},
+	})
+	e.Remove(e.ItemAt(sim.Pair{X: 1.1, Y: 1}))
+	if len(e.Level.Obstacles) != 1 || e.Level.Obstacles[0].X != 3 || !e.Changed {
+		t.Errorf("left %+v", e.Level.Obstacles)
+	}
+	e.Remove(e.ItemAt(sim.Pair{X: 2.01, Y: 1.5}))
+	if len(e.Level.Spawns) != 0 {
+		t.Errorf("left spawns %+v", e.Level.Spawns)
+	}
+	e.Remove(e.ItemAt(sim.Pair{X: 2, Y: 2.5}))
+	if len(e.Level.Obstacles) != 1 {
+		t.Errorf("removing nothing left %+v", e.Level.Obstacles)
+	}
+}
+
+func TestResizeGoal(t *testing.T) {
+	e := NewEditor(Level{})
+	left := sim.Pair{X: 0.1, Y: 1.5}
+	steps := []struct {
+		at   sim.Pair
+		dy   float64
+		want map[string]float64
+	}{
+		{left, -1, map[string]float64{"left": 0.9}},
+		{left, -1, map[string]float64{"left": 0.8}},
+		{sim.Pair{X: 2, Y: 2.9}, -1, map[string]float64{"left": 0.8, "bottom": 0.9}},
+		{sim.Pair{X: 2, Y: 1.5}, -1, map[string]float64{"left": 0.8, "bottom": 0.9}},
+		{left, 1, map[string]float64{"left": 0.9, "bottom": 0.9}},
+		{left, 1, map[string]float64{"bottom": 0.9}},
+		{left, 1, map[string]float64{"bottom": 0.9}},
+	}
+	for i, s := range steps {
+		e.ResizeGoal(s.at, s.dy)
+		if len(e.Level.Goals) != len(s.want) {
+			t.Fatalf("step %d: goals %v, want %v", i+1, e.Level.Goals, s.want)
+		}
+		for name, size := range s.want {
+			if e.Level.Goals[name] != size {
+				t.Fatalf("step %d: goals %v, want %v", i+1, e.Level.Goals, s.want)
+			}
+		}
+	}
+	for i := 0; i < 20; i++ {
+		e.ResizeGoal(left, -1)
+	}
+	if e.Level.Goals["left"] != 0.1 {
+		t.Errorf("smallest goal %g", e.Level.Goals["left"])
+	}
+}
+
+func TestDrag(t *testing.T) {
+	rect := Obstacle{Type: Rect, X: 1.5, Y: 1, W: 0.4, H: 0.8}
+	cases := []struct {
+		name  string
+		press sim.Pair
+		shift bool
+		to    sim.Pair
+		want  Obstacle
+		// placed is set when the press should have missed the obstacle
+		// and put a new one down instead.
+		placed bool
+	}{
+		{name: "move", press: sim.Pair{X: 1.6, Y: 1.1}, to: sim.Pair{X: 2.12, Y: 0.88},
+			want: Obstacle{Type: Rect, X: 2, Y: 0.8, W: 0.4, H: 0.8}},
+		{name: "resize", press: sim.Pair{X: 1.92, Y: 1.81}, to: sim.Pair{X: 2.31, Y: 2.09},
+			want: Obstacle{Type: Rect, X: 1.5, Y: 1, W: 0.8, H: 1.1}},
+		{name: "resize to nothing", press: sim.Pair{X: 1.9, Y: 1.8}, to: sim.Pair{X: 1, Y: 0.5},
+			want: Obstacle{Type: Rect, X: 1.5, Y: 1, W: Grid, H: Grid}},
+		{name: "travel", press: sim.Pair{X: 1.7, Y: 1.4}, shift: true, to: sim.Pair{X: 1.71, Y: 1.92},
+			want: Obstacle{Type: Rect, X: 1.5, Y: 1, W: 0.4, H: 0.8, Move: sim.Pair{Y: 0.5}, Period: editPeriod}},
+		{name: "travel from the handle", press: sim.Pair{X: 1.9, Y: 1.8}, shift: true, to: sim.Pair{X: 2.9, Y: 1.8},
+			want: Obstacle{Type: Rect, X: 1.5, Y: 1, W: 0.4, H: 0.8, Move: sim.Pair{X: 1}, Period: editPeriod}},
+		{name: "travel back to nothing", press: sim.Pair{X: 1.7, Y: 1.4}, shift: true, to: sim.Pair{X: 1.7, Y: 1.4},
+			want: rect},
+		{name: "shift elsewhere", press: sim.Pair{X: 3, Y: 2}, shift: true, to: sim.Pair{X: 3, Y: 2.5},
+			want: rect, placed: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := NewEditor(Level{Obstacles: []Obstacle{rect}})
+			e.Selected = Item{Index: 0}
+			if err := e.Press(c.press, c.shift); err != nil {
+				t.Fatal(err)
+			}
+			// Drag through somewhere else first, as a real mouse would.
+			e.DragTo(sim.Pair{X: 2.5, Y: 2.2})
+			e.DragTo(c.to)
+			e.Release()
+			if !sameObstacle(e.Level.Obstacles[0], c.want) {
+				t.Errorf("obstacle is %+v, want %+v", e.Level.Obstacles[0], c.want)
+			}
+			if placed := len(e.Level.Obstacles) == 2; placed != c.placed {
+				t.Errorf("placed %v, want %v", placed, c.placed)
+			}
+			e.DragTo(sim.Pair{X: 1, Y: 1})
+			if !sameObstacle(e.Level.Obstacles[0], c.want) {
+				t.Error("moved after the drag ended")
+			}
+		})
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	e := NewEditor(Level{Obstacles: []Obstacle{{Type: Circle, X: 2, Y: 1.5, Radius: 0.2}}})
+	e.Selected = Item{Index: 0}
+	if err := e.Period(0.5); err == nil {
+		t.Error("gave a still obstacle a period")
+	}
+	e.Level.Obstacles[0].Move = sim.Pair{X: 0.5}
+	e.Level.Obstacles[0].Period = 1
+	for i := 0; i < 3; i++ {
+		if err := e.Period(-0.5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p := e.Level.Obstacles[0].Period; p != 0.5 {
+		t.Errorf("period %g, want 0.5", p)
+	}
+}
@@ -0,0 +1,313 @@
+// Package level reads arena layouts from JSON files and builds them into a
+// sim.Arena. Lengths are in arena units: the arena is sim.ArenaWidth by
+// sim.ArenaHeight with the origin in the top left corner. A level looks
+// like:
+//
+//	{
+//	  "Name": "Pillars",
+//	  "Goals": {"left": 0.6, "right": 0.6},
+//	  "Obstacles": [
+//	    {"Type": "rect", "X": 1.9, "Y": 0.5, "W": 0.2, "H": 0.5},
+//	    {"Type": "circle", "X": 2, "Y": 1.9, "Radius": 0.15, "Move": {"X": 0, "Y": 0.3}, "Period": 4},
+//	    {"Type": "bumper", "X": 1, "Y": 1.5, "Radius": 0.1, "Restitution": 1.3}
+//	  ],
+//	  "Spawns": [{"X": 1.5, "Y": 0.75}, {"X": 2.5, "Y": 2.25}]
+//	}
+//
+// Rectangles are placed by their top left corner and circles and bumpers
+// by their centre. Goals are fractions of each wall; sides left out have
+// the whole wall as their goal.
+package level
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+const (
+	Rect   = "rect"
+	Circle = "circle"
+	// Bumper is a circle that kicks balls away faster.
+	Bumper = "bumper"
+)
+
+// DefaultBumper is a bumper's restitution when none is given.
+const DefaultBumper = 1.4
+
+// Margin is how close obstacles may come to the walls, leaving room for
+// the paddles.
+const Margin = sim.ArenaWidth / 8
+
+// Restitutions outside these would stop balls dead or fling them about.
+const (
+	MinRestitution = 0.5
+	MaxRestitution = 2
+)
+
+type Level struct {
+	Name      string
+	Goals     map[string]float64 `json:",omitempty"`
+	Obstacles []Obstacle         `json:",omitempty"`
+	Spawns    []sim.Pair         `json:",omitempty"`
+}
+
+// Obstacle is one thing in the level. W and H are for rectangles and
+// Radius for circles and bumpers. A moving obstacle travels by Move and
+// back every Period seconds.
+type Obstacle struct {
+	Type        string
+	X           float64
+	Y           float64
+	W           float64  `json:",omitempty"`
+	H           float64  `json:",omitempty"`
+	Radius      float64  `json:",omitempty"`
+	Restitution float64  `json:",omitempty"`
+	Move        sim.Pair `json:",omitempty"`
+	Period      float64  `json:",omitempty"`
+}
+
+var sideNames = map[string]sim.Side{
+	"left":   sim.Left,
+	"right":  sim.Right,
+	"top":    sim.Top,
+	"bottom": sim.Bottom,
+}
+
+// Arena checks the level and builds it. All problems are reported together.
+func (l *Level) Arena() (sim.Arena, error) {
+	var a sim.Arena
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	var sides []string
+	for name := range l.Goals {
+		sides = append(sides, name)
+	}
+	sort.Strings(sides)
+	for _, name := range sides {
+		side, ok := sideNames[name]
+		size := l.Goals[name]
+		switch {
+		case !ok:
+			problem("unknown goal %q, want left, right, top or bottom", name)
+		case size <= 0 || size > 1:
+			problem("goal %q must be more than 0 and at most 1 of the wall, not %g", name, size)
+		default:
+			a.Goals[side] = size
+		}
+	}
+
+	if len(l.Obstacles) > sim.MaxObstacles {
+		problem("%d obstacles, at most %d fit", len(l.Obstacles), sim.MaxObstacles)
+	}
+	for i, o := range l.Obstacles {
+		if i >= sim.MaxObstacles {
+			break
+		}
+		built, err := o.Build()
+		if err != nil {
+			problem("obstacle %d: %v", i+1, err)
+			continue
+		}
+		a.Obstacles[i] = built
+	}
+
+	if len(l.Spawns) > sim.MaxSpawns {
+		problem("%d spawn points, at most %d fit", len(l.Spawns), sim.MaxSpawns)
+	}
+	spawns := l.Spawns
+	if len(spawns) == 0 {
+		spawns = []sim.Pair{{X: sim.ArenaWidth / 2, Y: sim.ArenaHeight / 2}}
+	}
+	for i, p := range spawns {
+		if i >= sim.MaxSpawns {
+			break
+		}
+		if !inside(p.X, p.Y, 0, 0) {
+			problem("spawn point %d is too close to a wall", i+1)
+		}
+		for j, o := range a.Obstacles {
+			if o.Shape != sim.ShapeNone && o.Overlaps(p, sim.ArenaWidth/60) {
+				problem("spawn point %d is inside obstacle %d", i+1, j+1)
+			}
+		}
+		if len(l.Spawns) > 0 {
+			a.Spawns[i] = p
+			a.NumSpawns++
+		}
+	}
+
+	if len(problems) > 0 {
+		return a, fmt.Errorf("invalid level %q:\n  %s", l.Name, strings.Join(problems, "\n  "))
+	}
+	return a, nil
+}
+
+// Build checks the obstacle and turns it into a sim.Obstacle. Unless the
+// type is unknown, the shape is filled in even when there is a problem.
+func (o Obstacle) Build() (sim.Obstacle, error) {
+	built := sim.Obstacle{Restitution: o.Restitution, Move: o.Move, Period: o.Period}
+	var x, y, w, h float64
+	switch o.Type {
+	case Rect:
+		if o.W <= 0 || o.H <= 0 {
+			return built, errors.New("a rect needs a positive W and H")
+		}
+		built.Shape = sim.ShapeRect
+		built.Rect = sim.Rect{X: o.X, Y: o.Y, W: o.W, H: o.H}
+		x, y, w, h = o.X, o.Y, o.W, o.H
+	case Circle, Bumper:
+		if o.Radius <= 0 {
+			return built, fmt.Errorf("a %s needs a positive Radius", o.Type)
+		}
+		built.Shape = sim.ShapeCircle
+		built.Center = sim.Pair{X: o.X, Y: o.Y}
+		built.Radius = o.Radius
+		x, y, w, h = o.X-o.Radius, o.Y-o.Radius, 2*o.Radius, 2*o.Radius
+		if o.Type == Bumper && o.Restitution == 0 {
+			built.Restitution = DefaultBumper
+		}
+	default:
+		return built, fmt.Errorf("unknown type %q, want rect, circle or bumper", o.Type)
+	}
+
+	if r := built.Restitution; r != 0 && (r < MinRestitution || r > MaxRestitution) {
+		return built, fmt.Errorf("Restitution must be between %g and %g", float64(MinRestitution), float64(MaxRestitution))
+	}
+	if o.Period < 0 {
+		return built, errors.New("Period must not be negative")
+	}
+	if o.Period == 0 && (o.Move.X != 0 || o.Move.Y != 0) {
+		return built, errors.New("a moving obstacle needs a Period")
+	}
+	if !inside(x, y, w, h) || !inside(x+o.Move.X, y+o.Move.Y, w, h) {
+		return built, fmt.Errorf("must stay %g from the walls", Margin)
+	}
+	return built, nil
+}
+
+// inside reports whether a box stays Margin away from every wall.
+func inside(x, y, w, h float64) bool {
+	return x >= Margin && y >= Margin && x+w <= sim.ArenaWidth-Margin && y+h <= sim.ArenaHeight-Margin
+}
+
+func Parse(data []byte) (*Level, error) {
+	var l Level
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, err
+	}
+	if _, err := l.Arena(); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func Load(path string) (*Level, error) {
+	l, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := l.Arena(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return l, nil
+}
+
+// Open reads a level without checking it, so one with problems can still
+// be fixed in the editor.
+func Open(path string) (*Level, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var l Level
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if l.Name == "" {
+		l.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return &l, nil
+}
+
+// Save checks the level and writes it to path.
+func Save(path string, l *Level) error {
+	if _, err := l.Arena(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Find returns the built in level with the given name, or failing that
+// loads name as a file.
+func Find(name string) (*Level, error) {
+	for i := range Builtin {
+		if strings.EqualFold(Builtin[i].Name, name) {
+			l := Builtin[i]
+			return &l, nil
+		}
+	}
+	l, err := Load(name)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no level called %q and no such file", name)
+	}
+	return l, err
+}
+
+// List returns the built in levels followed by those in the user's level
+// directory. Files that can't be loaded are left out and reported in the
+// error, alongside the levels that could.
+func List() ([]Level, error) {
+	levels := append([]Level(nil), Builtin...)
+	dir, err := Dir()
+	if err != nil {
+		return levels, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return levels, err
+	}
+	var problems []string
+	for _, path := range paths {
+		l, err := Load(path)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		levels = append(levels, *l)
+	}
+	if len(problems) > 0 {
+		return levels, errors.New(strings.Join(problems, "\n"))
+	}
+	return levels, nil
+}
+
+// Dir is where the user's own levels are kept.
+func Dir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pong", "levels"), nil
+}
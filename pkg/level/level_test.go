@@ -0,0 +1,110 @@
+package level
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+func TestArena(t *testing.T) {
+	rect := Obstacle{Type: Rect, X: 1.9, Y: 0.5, W: 0.2, H: 0.6}
+	tooMany := make([]Obstacle, sim.MaxObstacles+1)
+	for i := range tooMany {
+		tooMany[i] = rect
+	}
+	tests := []struct {
+		name  string
+		level Level
+		// want is part of the error, or empty if the level is fine.
+		want string
+	}{
+		{"empty", Level{}, ""},
+		{"goals", Level{Goals: map[string]float64{"left": 0.5, "top": 1}}, ""},
+		{"unknown goal", Level{Goals: map[string]float64{"middle": 0.5}}, `unknown goal "middle"`},
+		{"no goal", Level{Goals: map[string]float64{"left": 0}}, `goal "left" must be more than 0`},
+		{"goal too wide", Level{Goals: map[string]float64{"right": 1.5}}, `goal "right" must be more than 0 and at most 1`},
+		{"most obstacles", Level{Obstacles: tooMany[:sim.MaxObstacles]}, ""},
+		{"too many obstacles", Level{Obstacles: tooMany}, "obstacles, at most"},
+		{"bad obstacle", Level{Obstacles: []Obstacle{rect, {Type: Circle, X: 2, Y: 1.5}}}, "obstacle 2: a circle needs a positive Radius"},
+		{"spawns", Level{Spawns: []sim.Pair{{X: 1, Y: 1}, {X: 3, Y: 2}}}, ""},
+		{"spawn by a wall", Level{Spawns: []sim.Pair{{X: 1, Y: 1}, {X: 0.2, Y: 1.5}}}, "spawn point 2 is too close to a wall"},
+		{"spawn in an obstacle", Level{Obstacles: []Obstacle{rect}, Spawns: []sim.Pair{{X: 2, Y: 0.8}}}, "spawn point 1 is inside obstacle 1"},
+		{"middle in an obstacle", Level{Obstacles: []Obstacle{{Type: Circle, X: 2, Y: 1.5, Radius: 0.2}}}, "spawn point 1 is inside obstacle 1"},
+		{"too many spawns", Level{Spawns: make([]sim.Pair, sim.MaxSpawns+1)}, "spawn points, at most"},
+	}
+	for _, test := range tests {
+		_, err := test.level.Arena()
+		switch {
+		case test.want == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.want != "" && err == nil:
+			t.Errorf("%s: no error, want one about %q", test.name, test.want)
+		case test.want != "" && !strings.Contains(err.Error(), test.want):
+			t.Errorf("%s: %v, want an error about %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name     string
+		obstacle Obstacle
+		want     string
+	}{
+		{"rect", Obstacle{Type: Rect, X: 1, Y: 1, W: 0.5, H: 0.5}, ""},
+		{"bumper", Obstacle{Type: Bumper, X: 2, Y: 1.5, Radius: 0.2, Restitution: MaxRestitution}, ""},
+		{"unknown type", Obstacle{Type: "square", X: 1, Y: 1, W: 0.5, H: 0.5}, `unknown type "square"`},
+		{"flat rect", Obstacle{Type: Rect, X: 1, Y: 1, W: 0.5}, "positive W and H"},
+		{"no radius", Obstacle{Type: Bumper, X: 2, Y: 1.5}, "positive Radius"},
+		{"too bouncy", Obstacle{Type: Circle, X: 2, Y: 1.5, Radius: 0.2, Restitution: MaxRestitution + 0.1}, "Restitution must be between"},
+		{"too dead", Obstacle{Type: Rect, X: 1, Y: 1, W: 0.5, H: 0.5, Restitution: MinRestitution - 0.1}, "Restitution must be between"},
+		{"moving", Obstacle{Type: Rect, X: 1, Y: 1, W: 0.5, H: 0.5, Move: sim.Pair{X: 1}, Period: 2}, ""},
+		{"moving with no period", Obstacle{Type: Rect, X: 1, Y: 1, W: 0.5, H: 0.5, Move: sim.Pair{X: 1}}, "needs a Period"},
+		{"negative period", Obstacle{Type: Rect, X: 1, Y: 1, W: 0.5, H: 0.5, Move: sim.Pair{X: 1}, Period: -1}, "must not be negative"},
+		{"by a wall", Obstacle{Type: Circle, X: 0.5, Y: 1.5, Radius: 0.2}, "from the walls"},
+		{"moving into a wall", Obstacle{Type: Rect, X: 1, Y: 1, W: 0.5, H: 0.5, Move: sim.Pair{Y: 1.5}, Period: 2}, "from the walls"},
+	}
+	for _, test := range tests {
+		_, err := test.obstacle.Build()
+		switch {
+		case test.want == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.want != "" && err == nil:
+			t.Errorf("%s: no error, want one about %q", test.name, test.want)
+		case test.want != "" && !strings.Contains(err.Error(), test.want):
+			t.Errorf("%s: %v, want an error about %q", test.name, err, test.want)
+		}
+	}
+	if built, _ := (Obstacle{Type: Bumper, X: 2, Y: 1.5, Radius: 0.2}).Build(); built.Restitution != DefaultBumper {
+		t.Errorf("bumper built with restitution %g, want %g", built.Restitution, DefaultBumper)
+	}
+}
+
+// TestDocExample checks the level in the package documentation is a valid
+// one.
+func TestDocExample(t *testing.T) {
+	src, err := ioutil.ReadFile("level.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var example []string
+	for _, line := range strings.Split(string(src), "\n") {
+		if strings.HasPrefix(line, "//\t") {
+			example = append(example, strings.TrimPrefix(line, "//\t"))
+		}
+	}
+	l, err := Parse([]byte(strings.Join(example, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Name != "Pillars" || len(l.Goals) != 2 || len(l.Obstacles) != 3 || len(l.Spawns) != 2 {
+		t.Errorf("example parsed as %+v", l)
+	}
+	for _, l := range Builtin {
+		if _, err := l.Arena(); err != nil {
+			t.Error(err)
+		}
+	}
+}
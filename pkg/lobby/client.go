@@ -0,0 +1,132 @@
+package lobby
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Client is a player's connection to a lobby. Messages arrive in order,
+// both answers and news about the player's room, and are read with Next.
+type Client struct {
+	// Rating is the player's rating as of the last welcome or result.
+	Rating float64
+
+	conn    net.Conn
+	scanner *bufio.Scanner
+	enc     *json.Encoder
+}
+
+// Dial connects to the lobby at addr as name. port is the UDP port the
+// player hosts matches on.
+func Dial(addr, name string, port int) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{conn: conn, scanner: bufio.NewScanner(conn), enc: json.NewEncoder(conn)}
+	if err := c.Send(Request{Cmd: "hello", Name: name, Port: port}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := c.Wait(MsgWelcome); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) Send(req Request) error {
+	return c.enc.Encode(req)
+}
+
+// Next returns the next message from the lobby.
+func (c *Client) Next() (Message, error) {
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			return Message{}, err
+		}
+		return Message{}, errors.New("the lobby closed the connection")
+	}
+	var m Message
+	if err := json.Unmarshal(c.scanner.Bytes(), &m); err != nil {
+		return Message{}, err
+	}
+	if m.Type == MsgWelcome || m.Type == MsgRating {
+		c.Rating = m.Rating
+	}
+	return m, nil
+}
+
+// Wait skips messages until one of type typ arrives. An error message from
+// the lobby is returned as an error.
+func (c *Client) Wait(typ string) (Message, error) {
+	for {
+		m, err := c.Next()
+		if err != nil {
+			return m, err
+		}
+		if m.Type == MsgError {
+			return m, errors.New(m.Error)
+		}
+		if m.Type == typ {
+			return m, nil
+		}
+	}
+}
+
+// List returns the rooms that can be joined.
+func (c *Client) List() ([]RoomInfo, error) {
+	if err := c.Send(Request{Cmd: "list"}); err != nil {
+		return nil, err
+	}
+	m, err := c.Wait(MsgRooms)
+	return m.Rooms, err
+}
+
+func (c *Client) Create(room, password string) error {
+	return c.Send(Request{Cmd: "create", Room: room, Password: password})
+}
+
+func (c *Client) Join(room, password string) error {
+	return c.Send(Request{Cmd: "join", Room: room, Password: password})
+}
+
+func (c *Client) Quick() error {
+	return c.Send(Request{Cmd: "quick"})
+}
+
+func (c *Client) Ready(ready bool) error {
+	return c.Send(Request{Cmd: "ready", Ready: ready})
+}
+
+func (c *Client) Leave() error {
+	return c.Send(Request{Cmd: "leave"})
+}
+
+// Report tells the lobby how the match went and waits for the new rating,
+// which comes once the other player has reported too or has left.
+func (c *Client) Report(won bool) error {
+	if err := c.Send(Request{Cmd: "report", Won: won}); err != nil {
+		return err
+	}
+	_, err := c.Wait(MsgRating)
+	return err
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (r RoomInfo) String() string {
+	s := r.Name
+	if r.Locked {
+		s += " (locked)"
+	}
+	for _, p := range r.Players {
+		s += fmt.Sprintf(" %s [%.0f]", p.Name, p.Rating)
+	}
+	return s
+}
@@ -0,0 +1,416 @@
+// Package lobby helps players on the same network find each other. Players
+// open named rooms, optionally with a password, join the rooms others have
+// opened or ask to be paired with someone of a similar rating. Once both
+// players in a room say they are ready, the lobby tells them how to reach
+// each other and they play peer to peer with pkg/netplay.
+//
+// Clients talk to the lobby with one JSON Request or Message per line.
+package lobby
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// pairInterval is how often the quick match queue is looked at.
+	pairInterval = 500 * time.Millisecond
+	// The rating gap quick match accepts starts at pairWindow and widens
+	// by pairWiden every second the players have waited.
+	pairWindow  = 100
+	pairWiden   = 50
+	maxNameLen  = 32
+	sendTimeout = 5 * time.Second
+)
+
+type Lobby struct {
+	// RatingsPath, if set, is where ratings are saved after every match.
+	RatingsPath string
+
+	mu      sync.Mutex
+	ratings Ratings
+	players map[*player]bool
+	rooms   map[string]*room
+	queue   []*player
+	quick   int
+}
+
+type player struct {
+	name     string
+	host     string
+	port     int
+	conn     net.Conn
+	mu       sync.Mutex
+	enc      *json.Encoder
+	room     *room
+	ready    bool
+	queuedAt time.Time
+}
+
+type room struct {
+	name     string
+	password string
+	// hidden rooms are made by quick match and aren't listed.
+	hidden  bool
+	started bool
+	players []*player
+	reports map[*player]bool
+}
+
+func New(ratings Ratings) *Lobby {
+	if ratings == nil {
+		ratings = Ratings{}
+	}
+	return &Lobby{
+		ratings: ratings,
+		players: make(map[*player]bool),
+		rooms:   make(map[string]*room),
+	}
+}
+
+func (l *Lobby) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("lobby listening on %s", ln.Addr())
+	return l.Serve(ln)
+}
+
+func (l *Lobby) Serve(ln net.Listener) error {
+	defer ln.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go l.pairLoop(stop)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go l.handle(conn)
+	}
+}
+
+func (l *Lobby) handle(conn net.Conn) {
+	defer conn.Close()
+	host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	p := &player{host: host, conn: conn, enc: json.NewEncoder(conn)}
+	defer l.remove(p)
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			p.send(Message{Type: MsgError, Error: err.Error()})
+			continue
+		}
+		if err := l.do(p, req); err != nil {
+			p.send(Message{Type: MsgError, Error: err.Error()})
+		}
+	}
+}
+
+func (l *Lobby) do(p *player, req Request) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if req.Cmd != "hello" && p.name == "" {
+		return errors.New("say hello first")
+	}
+	switch req.Cmd {
+	case "hello":
+		return l.hello(p, req)
+	case "list":
+		p.send(Message{Type: MsgRooms, Rooms: l.openRooms()})
+	case "create":
+		return l.create(p, req.Room, req.Password)
+	case "join":
+		return l.join(p, req.Room, req.Password)
+	case "quick":
+		l.leave(p)
+		p.queuedAt = time.Now()
+		l.queue = append(l.queue, p)
+		p.send(Message{Type: MsgQueued})
+	case "ready":
+		return l.setReady(p, req.Ready)
+	case "leave":
+		l.leave(p)
+	case "report":
+		return l.report(p, req.Won)
+	default:
+		return fmt.Errorf("unknown command %q", req.Cmd)
+	}
+	return nil
+}
+
+func (l *Lobby) hello(p *player, req Request) error {
+	if p.name != "" {
+		return errors.New("already said hello")
+	}
+	if req.Name == "" || len(req.Name) > maxNameLen {
+		return fmt.Errorf("names must be 1 to %d bytes long", maxNameLen)
+	}
+	if req.Port <= 0 || req.Port > 65535 {
+		return errors.New("a port to host matches on is needed")
+	}
+	for other := range l.players {
+		if other.name == req.Name {
+			return fmt.Errorf("%s is already here", req.Name)
+		}
+	}
+	p.name, p.port = req.Name, req.Port
+	l.players[p] = true
+	p.send(Message{Type: MsgWelcome, Rating: l.ratings.Get(p.name)})
+	return nil
+}
+
+func (l *Lobby) openRooms() []RoomInfo {
+	var rooms []RoomInfo
+	for _, r := range l.rooms {
+		if !r.hidden && !r.started && len(r.players) < 2 {
+			rooms = append(rooms, l.info(r))
+		}
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Name < rooms[j].Name })
+	return rooms
+}
+
+func (l *Lobby) info(r *room) RoomInfo {
+	info := RoomInfo{Name: r.name, Locked: r.password != ""}
+	for _, p := range r.players {
+		info.Players = append(info.Players, PlayerInfo{Name: p.name, Rating: l.ratings.Get(p.name), Ready: p.ready})
+	}
+	return info
+}
+
+func (l *Lobby) create(p *player, name, password string) error {
+	if name == "" {
+		return errors.New("rooms need a name")
+	}
+	if _, ok := l.rooms[name]; ok {
+		return fmt.Errorf("there is already a room called %s", name)
+	}
+	l.leave(p)
+	r := &room{name: name, password: password}
+	l.rooms[name] = r
+	l.enter(p, r)
+	return nil
+}
+
+func (l *Lobby) join(p *player, name, password string) error {
+	r, ok := l.rooms[name]
+	if !ok || r.hidden {
+		return fmt.Errorf("no room called %s", name)
+	}
+	if r.password != password {
+		return errors.New("wrong password")
+	}
+	if len(r.players) >= 2 || r.started {
+		return fmt.Errorf("%s is full", name)
+	}
+	if p.room == r {
+		return nil
+	}
+	l.leave(p)
+	l.enter(p, r)
+	return nil
+}
+
+func (l *Lobby) enter(p *player, r *room) {
+	p.room, p.ready = r, false
+	r.players = append(r.players, p)
+	l.update(r)
+}
+
+// update tells everyone in r how it looks now.
+func (l *Lobby) update(r *room) {
+	info := l.info(r)
+	for _, p := range r.players {
+		p.send(Message{Type: MsgRoom, Room: &info})
+	}
+}
+
+// leave takes p out of their room and the quick match queue. Leaving in
+// the middle of a match forfeits it. Empty rooms are closed.
+func (l *Lobby) leave(p *player) {
+	for i, q := range l.queue {
+		if q == p {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			break
+		}
+	}
+	r := p.room
+	if r == nil {
+		return
+	}
+	p.room, p.ready = nil, false
+	for i, q := range r.players {
+		if q == p {
+			r.players = append(r.players[:i], r.players[i+1:]...)
+			break
+		}
+	}
+	if r.started {
+		l.forfeit(r, p)
+	}
+	if len(r.players) == 0 || r.hidden {
+		for _, q := range r.players {
+			q.room, q.ready = nil, false
+			q.send(Message{Type: MsgError, Error: p.name + " left"})
+		}
+		delete(l.rooms, r.name)
+		return
+	}
+	r.started = false
+	l.update(r)
+}
+
+func (l *Lobby) remove(p *player) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.leave(p)
+	delete(l.players, p)
+}
+
+// setReady is the ready check. When both players are ready the room is
+// handed over to the game: the player who opened it hosts.
+func (l *Lobby) setReady(p *player, ready bool) error {
+	r := p.room
+	if r == nil {
+		return errors.New("not in a room")
+	}
+	if r.started {
+		return errors.New("the match has started")
+	}
+	p.ready = ready
+	l.update(r)
+	if len(r.players) < 2 || !r.players[0].ready || !r.players[1].ready {
+		return nil
+	}
+
+	r.started = true
+	r.reports = make(map[*player]bool)
+	host, guest := r.players[0], r.players[1]
+	host.send(Message{Type: MsgStart, Start: &Start{Host: true, Port: host.port, Opponent: guest.name}})
+	guest.send(Message{Type: MsgStart, Start: &Start{
+		Addr:     net.JoinHostPort(host.host, strconv.Itoa(host.port)),
+		Opponent: host.name,
+	}})
+	return nil
+}
+
+// report takes a player's word for the result of their match. Ratings only
+// change once both players agree on who won, after which the room is ready
+// for a rematch.
+func (l *Lobby) report(p *player, won bool) error {
+	r := p.room
+	if r == nil || !r.started {
+		return errors.New("not playing a match")
+	}
+	r.reports[p] = won
+	if len(r.reports) < 2 {
+		return nil
+	}
+	a, b := r.players[0], r.players[1]
+	if r.reports[a] != r.reports[b] {
+		winner, loser := a, b
+		if r.reports[b] {
+			winner, loser = b, a
+		}
+		l.ratings.Record(winner.name, loser.name)
+		l.saveRatings()
+	}
+	r.started = false
+	for _, q := range r.players {
+		q.ready = false
+		q.send(Message{Type: MsgRating, Rating: l.ratings.Get(q.name)})
+	}
+	l.update(r)
+	return nil
+}
+
+// forfeit settles the match in r that p walked out of: whoever is left
+// wins, and hears their new rating rather than waiting for p's report.
+func (l *Lobby) forfeit(r *room, p *player) {
+	r.started = false
+	for _, q := range r.players {
+		l.ratings.Record(q.name, p.name)
+		l.saveRatings()
+		q.ready = false
+		q.send(Message{Type: MsgRating, Rating: l.ratings.Get(q.name)})
+	}
+}
+
+func (l *Lobby) saveRatings() {
+	if l.RatingsPath == "" {
+		return
+	}
+	if err := l.ratings.Save(l.RatingsPath); err != nil {
+		log.Printf("saving ratings: %v", err)
+	}
+}
+
+func (l *Lobby) pairLoop(stop chan struct{}) {
+	ticker := time.NewTicker(pairInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			l.mu.Lock()
+			l.pair(now)
+			l.mu.Unlock()
+		}
+	}
+}
+
+// pair matches up queued players whose ratings are close enough, given how
+// long they have waited, putting each pair in a room of their own.
+func (l *Lobby) pair(now time.Time) {
+	sort.Slice(l.queue, func(i, j int) bool {
+		return l.ratings.Get(l.queue[i].name) < l.ratings.Get(l.queue[j].name)
+	})
+	var left []*player
+	for i := 0; i < len(l.queue); i++ {
+		p := l.queue[i]
+		if i+1 < len(l.queue) {
+			q := l.queue[i+1]
+			wait := now.Sub(p.queuedAt)
+			if w := now.Sub(q.queuedAt); w < wait {
+				wait = w
+			}
+			window := pairWindow + pairWiden*wait.Seconds()
+			if l.ratings.Get(q.name)-l.ratings.Get(p.name) <= window {
+				l.quick++
+				r := &room{name: fmt.Sprintf("quick-%d", l.quick), hidden: true}
+				l.rooms[r.name] = r
+				l.enter(p, r)
+				l.enter(q, r)
+				i++
+				continue
+			}
+		}
+		left = append(left, p)
+	}
+	l.queue = left
+}
+
+// send writes m to p. It is called with the lobby locked, so a player who
+// stops reading is given up on rather than waited for.
+func (p *player) send(m Message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.conn.SetWriteDeadline(time.Now().Add(sendTimeout))
+	if err := p.enc.Encode(m); err != nil {
+		p.conn.Close()
+	}
+}
@@ -0,0 +1,261 @@
+package lobby
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// start runs a lobby on a free local port, returning it so it can be shut
+// down.
+func start(t *testing.T, ratings Ratings) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go New(ratings).Serve(ln)
+	return ln
+}
+
+func dial(t *testing.T, ln net.Listener, name string, port int) *Client {
+	t.Helper()
+	c, err := Dial(ln.Addr().String(), name, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// expect waits for a message of type typ, failing on an error message or if
+// none comes soon.
+func expect(t *testing.T, c *Client, typ string) Message {
+	t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer c.conn.SetReadDeadline(time.Time{})
+	m, err := c.Wait(typ)
+	if err != nil {
+		t.Fatalf("waiting for %s: %v", typ, err)
+	}
+	return m
+}
+
+// expectError waits for an error message mentioning want.
+func expectError(t *testing.T, c *Client, want string) {
+	t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer c.conn.SetReadDeadline(time.Time{})
+	for {
+		m, err := c.Next()
+		if err != nil {
+			t.Fatalf("waiting for an error about %q: %v", want, err)
+		}
+		if m.Type == MsgError {
+			if !strings.Contains(m.Error, want) {
+				t.Fatalf("got error %q, want one about %q", m.Error, want)
+			}
+			return
+		}
+	}
+}
+
+// roomOf waits for news of c's room with n players in it.
+func roomOf(t *testing.T, c *Client, n int) RoomInfo {
+	t.Helper()
+	for {
+		m := expect(t, c, MsgRoom)
+		if len(m.Room.Players) == n {
+			return *m.Room
+		}
+	}
+}
+
+// startMatch readies both players of a full room and checks the lobby
+// tells them how to find each other.
+func startMatch(t *testing.T, host, guest *Client, hostPort int) {
+	t.Helper()
+	if err := host.Ready(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := guest.Ready(true); err != nil {
+		t.Fatal(err)
+	}
+	h, g := expect(t, host, MsgStart).Start, expect(t, guest, MsgStart).Start
+	if !h.Host || h.Port != hostPort {
+		t.Errorf("host told %+v", h)
+	}
+	if g.Host || g.Addr != net.JoinHostPort("127.0.0.1", strconv.Itoa(hostPort)) {
+		t.Errorf("guest told %+v", g)
+	}
+}
+
+func TestNamedRoom(t *testing.T) {
+	ln := start(t, nil)
+	defer ln.Close()
+	alice := dial(t, ln, "alice", 7001)
+	defer alice.Close()
+	bob := dial(t, ln, "bob", 7002)
+	defer bob.Close()
+	carol := dial(t, ln, "carol", 7003)
+	defer carol.Close()
+
+	if _, err := Dial(ln.Addr().String(), "alice", 7004); err == nil {
+		t.Error("two players called alice")
+	}
+
+	if err := alice.Create("den", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	roomOf(t, alice, 1)
+	rooms, err := bob.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 1 || rooms[0].Name != "den" || !rooms[0].Locked || rooms[0].Players[0].Name != "alice" {
+		t.Fatalf("rooms %+v", rooms)
+	}
+
+	bob.Join("den", "guess")
+	expectError(t, bob, "wrong password")
+	bob.Join("den", "secret")
+	room := roomOf(t, bob, 2)
+	if room.Players[0].Name != "alice" || room.Players[1].Name != "bob" {
+		t.Errorf("room %+v", room)
+	}
+	roomOf(t, alice, 2)
+
+	carol.Join("den", "secret")
+	expectError(t, carol, "full")
+	if rooms, err := carol.List(); err != nil || len(rooms) != 0 {
+		t.Errorf("full room listed: %+v, %v", rooms, err)
+	}
+}
+
+func TestReadyAndReport(t *testing.T) {
+	ln := start(t, nil)
+	defer ln.Close()
+	alice := dial(t, ln, "alice", 7001)
+	defer alice.Close()
+	bob := dial(t, ln, "bob", 7002)
+	defer bob.Close()
+	alice.Create("den", "")
+	roomOf(t, alice, 1)
+	bob.Join("den", "")
+	roomOf(t, alice, 2)
+	roomOf(t, bob, 2)
+
+	// One player being ready isn't enough.
+	alice.Ready(true)
+	for {
+		room := roomOf(t, bob, 2)
+		if room.Players[0].Ready {
+			break
+		}
+	}
+	alice.Ready(false)
+	for {
+		room := roomOf(t, bob, 2)
+		if !room.Players[0].Ready {
+			break
+		}
+	}
+	bob.Ready(true)
+	for {
+		room := roomOf(t, alice, 2)
+		if !room.Players[0].Ready && room.Players[1].Ready {
+			break
+		}
+	}
+
+	startMatch(t, alice, bob, 7001)
+
+	// Ratings only change once both have reported.
+	reported := make(chan error)
+	go func() { reported <- alice.Report(true) }()
+	select {
+	case err := <-reported:
+		t.Fatalf("report returned before the other player's: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := bob.Report(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-reported; err != nil {
+		t.Fatal(err)
+	}
+	if alice.Rating != InitialRating+ratingK/2 || bob.Rating != InitialRating-ratingK/2 {
+		t.Errorf("ratings %g and %g after the first match", alice.Rating, bob.Rating)
+	}
+
+	// The room is ready for a rematch.
+	startMatch(t, alice, bob, 7001)
+}
+
+func TestLeaveMidMatch(t *testing.T) {
+	ln := start(t, nil)
+	defer ln.Close()
+	alice := dial(t, ln, "alice", 7001)
+	defer alice.Close()
+	bob := dial(t, ln, "bob", 7002)
+	defer bob.Close()
+	alice.Create("den", "")
+	roomOf(t, alice, 1)
+	bob.Join("den", "")
+	roomOf(t, alice, 2)
+	startMatch(t, alice, bob, 7001)
+
+	reported := make(chan error)
+	go func() { reported <- alice.Report(true) }()
+	time.Sleep(50 * time.Millisecond)
+	bob.Close()
+	select {
+	case err := <-reported:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("report still waiting after the other player left")
+	}
+	if alice.Rating <= InitialRating {
+		t.Errorf("rating %g after the other player walked out", alice.Rating)
+	}
+	roomOf(t, alice, 1)
+}
+
+func TestQuickMatch(t *testing.T) {
+	ln := start(t, Ratings{"dave": 1200, "erin": 1210, "frank": 2000})
+	defer ln.Close()
+	dave := dial(t, ln, "dave", 7001)
+	defer dave.Close()
+	frank := dial(t, ln, "frank", 7002)
+	defer frank.Close()
+	erin := dial(t, ln, "erin", 7003)
+	defer erin.Close()
+	for _, c := range []*Client{dave, frank, erin} {
+		if err := c.Quick(); err != nil {
+			t.Fatal(err)
+		}
+		expect(t, c, MsgQueued)
+	}
+
+	room := roomOf(t, dave, 2)
+	if room.Players[0].Name != "dave" || room.Players[1].Name != "erin" {
+		t.Errorf("paired %+v", room.Players)
+	}
+	roomOf(t, erin, 2)
+	if rooms, err := frank.List(); err != nil || len(rooms) != 0 {
+		t.Errorf("quick match room listed: %+v, %v", rooms, err)
+	}
+	startMatch(t, dave, erin, 7001)
+
+	// A quick match room closes when a player leaves, and the one left
+	// behind wins.
+	erin.Close()
+	m := expect(t, dave, MsgRating)
+	if m.Rating <= 1200 {
+		t.Errorf("rating %g after the other player walked out", m.Rating)
+	}
+	expectError(t, dave, "erin left")
+}
@@ -0,0 +1,65 @@
+package lobby
+
+// Request is a line sent by a client. Which fields matter depends on Cmd:
+//
+//	hello   Name, Port: must come first; Port is the UDP port the
+//	        player hosts matches on
+//	list    ask for the open rooms
+//	create  Room, Password: open a room and wait in it
+//	join    Room, Password: join someone's room
+//	quick   wait to be paired with someone of a similar rating
+//	ready   Ready: say whether you're ready to play
+//	leave   leave the room or the quick match queue
+//	report  Won: report the result of the match just played
+type Request struct {
+	Cmd      string
+	Name     string `json:",omitempty"`
+	Port     int    `json:",omitempty"`
+	Room     string `json:",omitempty"`
+	Password string `json:",omitempty"`
+	Ready    bool   `json:",omitempty"`
+	Won      bool   `json:",omitempty"`
+}
+
+// Message types sent by the lobby. Besides answering requests it tells
+// players in a room whenever the room changes.
+const (
+	MsgWelcome = "welcome"
+	MsgRooms   = "rooms"
+	MsgRoom    = "room"
+	MsgQueued  = "queued"
+	MsgStart   = "start"
+	MsgRating  = "rating"
+	MsgError   = "error"
+)
+
+// Message is a line sent by the lobby.
+type Message struct {
+	Type   string
+	Rating float64    `json:",omitempty"`
+	Rooms  []RoomInfo `json:",omitempty"`
+	Room   *RoomInfo  `json:",omitempty"`
+	Start  *Start     `json:",omitempty"`
+	Error  string     `json:",omitempty"`
+}
+
+type RoomInfo struct {
+	Name    string
+	Locked  bool
+	Players []PlayerInfo
+}
+
+type PlayerInfo struct {
+	Name   string
+	Rating float64
+	Ready  bool
+}
+
+// Start hands a ready room over to the game. The host listens on their
+// port and the other player connects to Addr.
+type Start struct {
+	Host     bool
+	Addr     string
+	Port     int
+	Opponent string
+}
@@ -0,0 +1,55 @@
+package lobby
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+)
+
+const (
+	// InitialRating is the rating of a player the lobby hasn't seen before.
+	InitialRating = 1200
+	// ratingK is how far one result moves a rating.
+	ratingK = 32
+)
+
+// Ratings are Elo ratings by player name.
+type Ratings map[string]float64
+
+func (r Ratings) Get(name string) float64 {
+	if rating, ok := r[name]; ok {
+		return rating
+	}
+	return InitialRating
+}
+
+// Record updates the ratings of both players after winner beat loser.
+func (r Ratings) Record(winner, loser string) {
+	w, l := r.Get(winner), r.Get(loser)
+	expected := 1 / (1 + math.Pow(10, (l-w)/400))
+	r[winner] = w + ratingK*(1-expected)
+	r[loser] = l - ratingK*(1-expected)
+}
+
+// LoadRatings reads ratings saved by Save. A missing file means nobody has
+// played yet.
+func LoadRatings(path string) (Ratings, error) {
+	r := Ratings{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, json.Unmarshal(data, &r)
+}
+
+func (r Ratings) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
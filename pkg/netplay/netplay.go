@@ -0,0 +1,289 @@
+// Package netplay lets two players on different machines share a match
+// peer to peer, GGPO style. Each peer runs the whole simulation. Local input
+// is delayed by a few ticks to give it time to reach the other side; when it
+// is late anyway the peer guesses it, and rolls back and replays the ticks
+// it got wrong once the real input arrives.
+//
+// This relies on sim.Step giving bit-identical results on both machines, so
+// both peers should run the same build.
+package netplay
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+const (
+	// MaxRollback is how many ticks a peer may run ahead of the last input
+	// it has from the other one before it waits for it.
+	MaxRollback = 16
+	// MaxDelay bounds the input delay a host may ask for.
+	MaxDelay = 30
+	// Timeout is how long a silent peer is waited for before giving up.
+	Timeout = 5 * time.Second
+	// bufferSize is the number of ticks of inputs and states kept. It has to
+	// cover the inputs the other peer hasn't acknowledged yet, which is at
+	// most about 2*(MaxRollback+MaxDelay).
+	bufferSize = 256
+	// maxSend bounds the number of inputs in one packet.
+	maxSend = 64
+)
+
+var (
+	ErrTimeout = errors.New("lost connection to the other player")
+	ErrClosed  = errors.New("the other player left")
+)
+
+// Setup is decided by the host and sent to the joining peer, since both
+// simulations have to start out the same.
+type Setup struct {
+	Version int
+	// Delay is the number of ticks local input is held back for.
+	Delay  int
+	Seed   int64
+	Config sim.Config
+}
+
+// Session is one end of a match.
+type Session struct {
+	// Local is the side this peer plays; the host is on the left.
+	Local sim.Side
+	Setup Setup
+	// Rollbacks counts the ticks simulated again after a wrong guess.
+	Rollbacks int
+
+	transport Transport
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	state sim.State
+	// tick is the number of ticks simulated so far.
+	tick int
+	// saved[t%bufferSize] is the state before tick t was simulated.
+	saved [bufferSize]sim.State
+	local [bufferSize]sim.Intent
+	// remote holds the other peer's inputs, and guessed the inputs used
+	// for them, which may differ for ticks at or after remoteTicks.
+	remote  [bufferSize]sim.Intent
+	guessed [bufferSize]sim.Intent
+	// Inputs are known for all ticks before localTicks and remoteTicks. The
+	// other peer has all local inputs before acked.
+	localTicks  int
+	remoteTicks int
+	acked       int
+	stalled     bool
+
+	mu        sync.Mutex
+	inbox     []inputMsg
+	lastHeard time.Time
+	err       error
+}
+
+func newSession(t Transport, local sim.Side) *Session {
+	s := &Session{
+		Local:     local,
+		transport: t,
+		ready:     make(chan struct{}),
+		lastHeard: time.Now(),
+	}
+	return s
+}
+
+// start resets the session to the first tick once the setup is known.
+// Inputs for the first Delay ticks can't have been sent by anyone, so they
+// are known to be idle.
+func (s *Session) start() {
+	s.state = sim.NewState(s.Setup.Config, s.Setup.Seed)
+	s.localTicks = s.Setup.Delay
+	s.remoteTicks = s.Setup.Delay
+	s.acked = s.Setup.Delay
+}
+
+// State is the current state of the match, including guesses about the
+// other player's recent input.
+func (s *Session) State() sim.State {
+	return s.state
+}
+
+// Tick is the number of ticks simulated so far.
+func (s *Session) Tick() int {
+	return s.tick
+}
+
+// Stalled reports whether the last Advance had to wait for the other peer.
+func (s *Session) Stalled() bool {
+	return s.stalled
+}
+
+// Advance adds the local player's input and simulates the next tick, first
+// correcting any earlier ticks simulated with a wrong guess. Only the events
+// of the new tick are returned. If the other peer is too far behind nothing
+// is simulated and Stalled reports true.
+func (s *Session) Advance(local sim.Intent) ([]sim.Event, error) {
+	rollback, err := s.receive()
+	if err != nil {
+		return nil, err
+	}
+
+	if rollback < s.tick {
+		s.state = s.saved[rollback%bufferSize]
+		for t := rollback; t < s.tick; t++ {
+			s.simulate(t)
+		}
+		s.Rollbacks += s.tick - rollback
+	}
+
+	s.stalled = s.tick-s.remoteTicks >= MaxRollback ||
+		s.tick+s.Setup.Delay-s.acked >= bufferSize-1
+	if s.stalled {
+		s.send()
+		return nil, nil
+	}
+
+	s.local[(s.tick+s.Setup.Delay)%bufferSize] = local
+	s.localTicks = s.tick + s.Setup.Delay + 1
+	s.send()
+
+	events := s.simulate(s.tick)
+	s.tick++
+	return events, nil
+}
+
+func (s *Session) simulate(t int) []sim.Event {
+	i := t % bufferSize
+	s.saved[i] = s.state
+
+	remote := s.remote[i]
+	if t >= s.remoteTicks {
+		// The best guess is that the other player is still doing what they
+		// were last seen doing.
+		remote = sim.Intent{}
+		if s.remoteTicks > 0 {
+			remote = s.remote[(s.remoteTicks-1)%bufferSize]
+		}
+	}
+	s.guessed[i] = remote
+
+	var in sim.Inputs
+	in[s.Local] = s.local[i]
+	in[1-s.Local] = remote
+	var events []sim.Event
+	s.state, events = sim.Step(s.state, in)
+	return events
+}
+
+// receive takes in the inputs read since the last call and returns the
+// first tick that was simulated with a wrong guess, or the current tick if
+// there is none.
+func (s *Session) receive() (int, error) {
+	s.mu.Lock()
+	inbox := s.inbox
+	s.inbox = nil
+	err := s.err
+	if err == nil && time.Since(s.lastHeard) > Timeout {
+		s.err = ErrTimeout
+		err = s.err
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return s.tick, err
+	}
+
+	rollback := s.tick
+	for _, m := range inbox {
+		if m.Ack > s.acked {
+			s.acked = m.Ack
+		}
+		if m.Start > s.remoteTicks {
+			continue
+		}
+		for j, in := range m.Inputs {
+			t := m.Start + j
+			if t < s.remoteTicks {
+				continue
+			}
+			if t >= s.remoteTicks+bufferSize-1 {
+				break
+			}
+			s.remote[t%bufferSize] = in
+			s.remoteTicks = t + 1
+			if t < s.tick && in != s.guessed[t%bufferSize] && t < rollback {
+				rollback = t
+			}
+		}
+	}
+	return rollback, nil
+}
+
+// send tells the other peer about every local input it hasn't
+// acknowledged, so a lost packet is made up for by the next one.
+func (s *Session) send() {
+	start := s.acked
+	end := s.localTicks
+	if end-start > maxSend {
+		end = start + maxSend
+	}
+	m := inputMsg{Ack: s.remoteTicks, Start: start}
+	for t := start; t < end; t++ {
+		m.Inputs = append(m.Inputs, s.local[t%bufferSize])
+	}
+	s.transport.Send(m.encode())
+}
+
+// read runs for the life of the session, answering handshakes and queueing
+// inputs for Advance.
+func (s *Session) read() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := s.transport.Recv(buf)
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		p := buf[:n]
+		if len(p) == 0 {
+			continue
+		}
+		switch p[0] {
+		case msgHello:
+			s.hello(p)
+		case msgWelcome:
+			s.welcome(p)
+		case msgInput:
+			m, err := decodeInput(p)
+			if err != nil {
+				continue
+			}
+			s.mu.Lock()
+			s.inbox = append(s.inbox, m)
+			s.lastHeard = time.Now()
+			s.mu.Unlock()
+		case msgBye:
+			s.fail(ErrClosed)
+			return
+		}
+	}
+}
+
+func (s *Session) fail(err error) {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.mu.Unlock()
+	s.readyOnce.Do(func() { close(s.ready) })
+}
+
+// Close tells the other peer the match is over and stops the session. The
+// goodbye is sent a few times since any one packet may be lost; if they all
+// are, the other peer times out instead.
+func (s *Session) Close() error {
+	for i := 0; i < 3; i++ {
+		s.transport.Send([]byte{msgBye})
+	}
+	s.fail(ErrClosed)
+	return s.transport.Close()
+}
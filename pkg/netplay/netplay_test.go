@@ -0,0 +1,192 @@
+package netplay
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// pipe is one end of an in-memory Transport. Like UDP it drops packets
+// rather than block when the other end falls behind.
+type pipe struct {
+	in     <-chan []byte
+	out    chan<- []byte
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newPipe() (*pipe, *pipe) {
+	ab, ba := make(chan []byte, 1024), make(chan []byte, 1024)
+	return &pipe{in: ba, out: ab, closed: make(chan struct{})},
+		&pipe{in: ab, out: ba, closed: make(chan struct{})}
+}
+
+func (p *pipe) Send(b []byte) error {
+	select {
+	case <-p.closed:
+		return errors.New("pipe closed")
+	case p.out <- append([]byte(nil), b...):
+	default:
+	}
+	return nil
+}
+
+func (p *pipe) Recv(b []byte) (int, error) {
+	select {
+	case <-p.closed:
+		return 0, errors.New("pipe closed")
+	case packet := <-p.in:
+		return copy(b, packet), nil
+	}
+}
+
+func (p *pipe) Close() error {
+	p.once.Do(func() { close(p.closed) })
+	return nil
+}
+
+const (
+	testTicks = 900
+	// Inputs stop changing this long before testTicks, so by then every
+	// guess about the other player is right.
+	settleTicks = 100
+)
+
+// script is what side does on tick t: sweeping up and down at its own pace,
+// and pressing start now and then to serve and restart.
+func script(side sim.Side, t int) sim.Intent {
+	if t >= testTicks-settleTicks {
+		return sim.Intent{Axis: 0.5}
+	}
+	period := 11 + 6*int(side)
+	in := sim.Intent{Axis: 1}
+	if (t/period)%2 == 1 {
+		in.Axis = -1
+	}
+	if t%240 < 3 {
+		in.Buttons = sim.ButtonStart
+	}
+	return in
+}
+
+// run advances s with the script until both peers have got to testTicks,
+// keeping on past it so the other peer still hears from this one. It sends
+// the state at testTicks on at.
+func run(s *Session, at chan<- sim.State, done <-chan struct{}, errs chan<- error) {
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		select {
+		case <-done:
+			errs <- nil
+			return
+		default:
+		}
+		if time.Now().After(deadline) {
+			errs <- errors.New("timed out")
+			return
+		}
+		tick := s.Tick()
+		if _, err := s.Advance(script(s.Local, tick+s.Setup.Delay)); err != nil {
+			errs <- err
+			return
+		}
+		if !s.Stalled() && s.Tick() == testTicks {
+			at <- s.State()
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLossyMatch(t *testing.T) {
+	a, b := newPipe()
+	match(t,
+		NewLossy(a, 10*time.Millisecond, 20*time.Millisecond, 0.2, 1),
+		NewLossy(b, 10*time.Millisecond, 20*time.Millisecond, 0.2, 2))
+}
+
+func TestUDPMatch(t *testing.T) {
+	a, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Dial(a.(*udpHost).conn.LocalAddr().String())
+	if err != nil {
+		a.Close()
+		t.Fatal(err)
+	}
+	match(t,
+		NewLossy(a, 5*time.Millisecond, 10*time.Millisecond, 0.1, 3),
+		NewLossy(b, 5*time.Millisecond, 10*time.Millisecond, 0.1, 4))
+}
+
+// match hosts a match on one end of a connection and joins it from the
+// other, checking both peers agree on how it went once their guesses about
+// each other have had time to settle.
+func match(t *testing.T, hostEnd, joinEnd Transport) {
+	t.Helper()
+	type hosted struct {
+		s   *Session
+		err error
+	}
+	hostc := make(chan hosted)
+	go func() {
+		s, err := Host(hostEnd, Setup{Delay: 2, Seed: 42, Config: sim.DefaultConfig()})
+		hostc <- hosted{s, err}
+	}()
+	joiner, err := Join(joinEnd, 5*time.Second)
+	if err != nil {
+		hostEnd.Close()
+		t.Fatal(err)
+	}
+	h := <-hostc
+	if h.err != nil {
+		t.Fatal(h.err)
+	}
+	host := h.s
+	defer host.Close()
+	defer joiner.Close()
+	if joiner.Setup.Seed != 42 || joiner.Setup.Delay != 2 {
+		t.Fatalf("joined with setup %+v", joiner.Setup)
+	}
+
+	hostAt, joinAt := make(chan sim.State, 1), make(chan sim.State, 1)
+	done := make(chan struct{})
+	errs := make(chan error, 2)
+	go run(host, hostAt, done, errs)
+	go run(joiner, joinAt, done, errs)
+
+	var states [2]sim.State
+	for got := 0; got < 2; {
+		select {
+		case states[0] = <-hostAt:
+			got++
+		case states[1] = <-joinAt:
+			got++
+		case err := <-errs:
+			close(done)
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if states[0].Stats.Ticks == 0 {
+		t.Error("the match never started")
+	}
+	if !reflect.DeepEqual(states[0], states[1]) {
+		t.Errorf("peers disagree at tick %d: scores %v and %v, balls %+v and %+v",
+			testTicks, states[0].Score, states[1].Score, states[0].Balls[0], states[1].Balls[0])
+	}
+	if host.Rollbacks == 0 && joiner.Rollbacks == 0 {
+		t.Error("no rollbacks over a lossy connection")
+	}
+	t.Logf("rollbacks: host %d, joiner %d", host.Rollbacks, joiner.Rollbacks)
+}
@@ -0,0 +1,178 @@
+package netplay
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// Version is bumped whenever the protocol or the simulation changes in a
+// way that stops older builds playing along.
+const Version = 7
+
+// Every packet starts with one of these.
+const (
+	msgHello byte = iota + 1
+	msgWelcome
+	msgInput
+	msgBye
+)
+
+// The joining peer says hello until the host answers with a welcome
+// carrying the Setup. Hellos are answered for as long as the session lasts
+// in case a welcome is lost.
+func (s *Session) hello(p []byte) {
+	if s.Local != sim.Left || len(p) < 2 {
+		return
+	}
+	data, err := json.Marshal(s.Setup)
+	if err != nil {
+		return
+	}
+	s.transport.Send(append([]byte{msgWelcome}, data...))
+	if int(p[1]) != Version {
+		// The other peer reports the mismatch when it gets the welcome.
+		return
+	}
+	s.mu.Lock()
+	s.lastHeard = time.Now()
+	s.mu.Unlock()
+	s.readyOnce.Do(func() { close(s.ready) })
+}
+
+func (s *Session) welcome(p []byte) {
+	if s.Local != sim.Right {
+		return
+	}
+	s.readyOnce.Do(func() {
+		var setup Setup
+		if err := json.Unmarshal(p[1:], &setup); err != nil {
+			s.fail(fmt.Errorf("bad welcome from host: %v", err))
+		} else {
+			s.Setup = setup
+		}
+		close(s.ready)
+	})
+}
+
+// Host waits for another player to join over t and starts a session with
+// them. The host plays on the left.
+func Host(t Transport, setup Setup) (*Session, error) {
+	if setup.Delay < 0 || setup.Delay > MaxDelay {
+		return nil, fmt.Errorf("input delay must be between 0 and %d ticks", MaxDelay)
+	}
+	setup.Version = Version
+	s := newSession(t, sim.Left)
+	s.Setup = setup
+	s.start()
+	go s.read()
+	<-s.ready
+	if err := s.readErr(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Join asks the host at the other end of t for a match. It gives up if
+// nothing is heard back within timeout.
+func Join(t Transport, timeout time.Duration) (*Session, error) {
+	s := newSession(t, sim.Right)
+	go s.read()
+	deadline := time.After(timeout)
+	retry := time.NewTicker(250 * time.Millisecond)
+	defer retry.Stop()
+	for {
+		t.Send([]byte{msgHello, Version})
+		select {
+		case <-s.ready:
+			if err := s.readErr(); err != nil {
+				t.Close()
+				return nil, err
+			}
+			if s.Setup.Version != Version {
+				t.Close()
+				return nil, fmt.Errorf("host runs protocol version %d, this build speaks %d", s.Setup.Version, Version)
+			}
+			s.mu.Lock()
+			s.lastHeard = time.Now()
+			s.mu.Unlock()
+			s.start()
+			return s, nil
+		case <-deadline:
+			t.Close()
+			return nil, errors.New("no answer from host")
+		case <-retry.C:
+		}
+	}
+}
+
+func (s *Session) readErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// inputMsg carries inputs for the ticks from Start on. Ack is the number of
+// ticks the sender has the receiver's inputs for.
+type inputMsg struct {
+	Ack    int
+	Start  int
+	Inputs []sim.Intent
+}
+
+const (
+	inputHeaderSize = 1 + 4 + 4 + 1
+	intentSize      = 8 + 8 + 1 + 1
+	flagTrack       = 1
+)
+
+func (m *inputMsg) encode() []byte {
+	p := make([]byte, inputHeaderSize, inputHeaderSize+len(m.Inputs)*intentSize)
+	p[0] = msgInput
+	binary.BigEndian.PutUint32(p[1:], uint32(m.Ack))
+	binary.BigEndian.PutUint32(p[5:], uint32(m.Start))
+	p[9] = byte(len(m.Inputs))
+	var b [intentSize]byte
+	for _, in := range m.Inputs {
+		binary.BigEndian.PutUint64(b[0:], math.Float64bits(in.Axis))
+		binary.BigEndian.PutUint64(b[8:], math.Float64bits(in.Target))
+		b[16] = 0
+		if in.Track {
+			b[16] = flagTrack
+		}
+		b[17] = byte(in.Buttons)
+		p = append(p, b[:]...)
+	}
+	return p
+}
+
+func decodeInput(p []byte) (inputMsg, error) {
+	if len(p) < inputHeaderSize {
+		return inputMsg{}, errors.New("short input packet")
+	}
+	m := inputMsg{
+		Ack:   int(binary.BigEndian.Uint32(p[1:])),
+		Start: int(binary.BigEndian.Uint32(p[5:])),
+	}
+	n := int(p[9])
+	p = p[inputHeaderSize:]
+	if len(p) != n*intentSize {
+		return inputMsg{}, errors.New("bad input packet length")
+	}
+	m.Inputs = make([]sim.Intent, n)
+	for i := range m.Inputs {
+		b := p[i*intentSize:]
+		m.Inputs[i] = sim.Intent{
+			Axis:    math.Float64frombits(binary.BigEndian.Uint64(b[0:])),
+			Target:  math.Float64frombits(binary.BigEndian.Uint64(b[8:])),
+			Track:   b[16]&flagTrack != 0,
+			Buttons: sim.Buttons(b[17]),
+		}
+	}
+	return m, nil
+}
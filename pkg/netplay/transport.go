@@ -0,0 +1,165 @@
+package netplay
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transport carries packets between the two peers. Packets may be lost,
+// duplicated or arrive out of order. Send must be safe to call from several
+// goroutines.
+type Transport interface {
+	Send(p []byte) error
+	// Recv blocks until a packet arrives and copies it into p.
+	Recv(p []byte) (int, error)
+	Close() error
+}
+
+// udpHost answers whoever sends it the first packet and ignores everyone
+// else.
+type udpHost struct {
+	conn *net.UDPConn
+	mu   sync.Mutex
+	peer *net.UDPAddr
+}
+
+// Listen opens a UDP transport on addr for Host.
+func Listen(addr string) (Transport, error) {
+	a, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", a)
+	if err != nil {
+		return nil, err
+	}
+	return &udpHost{conn: conn}, nil
+}
+
+func (u *udpHost) Send(p []byte) error {
+	u.mu.Lock()
+	peer := u.peer
+	u.mu.Unlock()
+	if peer == nil {
+		return errors.New("no peer yet")
+	}
+	_, err := u.conn.WriteToUDP(p, peer)
+	return err
+}
+
+func (u *udpHost) Recv(p []byte) (int, error) {
+	for {
+		n, from, err := u.conn.ReadFromUDP(p)
+		if err != nil {
+			return 0, err
+		}
+		u.mu.Lock()
+		if u.peer == nil {
+			u.peer = from
+		}
+		ok := u.peer.String() == from.String()
+		u.mu.Unlock()
+		if ok {
+			return n, nil
+		}
+	}
+}
+
+func (u *udpHost) Close() error {
+	return u.conn.Close()
+}
+
+type udpClient struct {
+	conn *net.UDPConn
+}
+
+// Dial opens a UDP transport to the host at addr for Join.
+func Dial(addr string) (Transport, error) {
+	a, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, a)
+	if err != nil {
+		return nil, err
+	}
+	return udpClient{conn: conn}, nil
+}
+
+func (u udpClient) Send(p []byte) error {
+	_, err := u.conn.Write(p)
+	return err
+}
+
+func (u udpClient) Recv(p []byte) (int, error) {
+	for {
+		n, err := u.conn.Read(p)
+		if err != nil {
+			// Sending to a host that isn't up yet makes the next read
+			// fail on some systems; keep waiting for it.
+			if ne, ok := err.(net.Error); ok && !ne.Timeout() && !isClosed(err) {
+				continue
+			}
+			return 0, err
+		}
+		return n, nil
+	}
+}
+
+func (u udpClient) Close() error {
+	return u.conn.Close()
+}
+
+func isClosed(err error) bool {
+	return strings.Contains(err.Error(), "use of closed network connection")
+}
+
+// Lossy wraps a transport to simulate a bad network, for trying out the
+// rollback locally. Every packet sent is held back for Latency plus up to
+// Jitter, which can reorder packets, and a Loss fraction of them is dropped.
+type Lossy struct {
+	Transport
+	Latency time.Duration
+	Jitter  time.Duration
+	Loss    float64
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func NewLossy(t Transport, latency, jitter time.Duration, loss float64, seed int64) *Lossy {
+	return &Lossy{
+		Transport: t,
+		Latency:   latency,
+		Jitter:    jitter,
+		Loss:      loss,
+		rand:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (l *Lossy) Send(p []byte) error {
+	l.mu.Lock()
+	drop := l.rand.Float64() < l.Loss
+	delay := l.Latency
+	if l.Jitter > 0 {
+		delay += time.Duration(l.rand.Int63n(int64(l.Jitter)))
+	}
+	l.mu.Unlock()
+	if drop {
+		return nil
+	}
+	p = append([]byte(nil), p...)
+	time.AfterFunc(delay, func() { l.Transport.Send(p) })
+	return nil
+}
+
+// Close closes the wrapped transport once the packets still in flight have
+// been delivered.
+func (l *Lossy) Close() error {
+	time.AfterFunc(l.Latency+l.Jitter, func() { l.Transport.Close() })
+	return nil
+}
@@ -0,0 +1,103 @@
+package replay
+
+import (
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// keyframeInterval is how many ticks apart the player remembers states, so
+// seeking backwards only has to re-simulate a little.
+const keyframeInterval = sim.TickRate * 5
+
+// Player steps through a replay, and can seek to any tick by re-simulating
+// from the nearest earlier keyframe.
+type Player struct {
+	Replay *Replay
+	State  sim.State
+
+	tick      int
+	ticks     int
+	run       int
+	inRun     int
+	keyframes []sim.State
+}
+
+func NewPlayer(r *Replay) *Player {
+	return &Player{
+		Replay:    r,
+		State:     r.Initial,
+		ticks:     r.Ticks(),
+		keyframes: []sim.State{r.Initial},
+	}
+}
+
+// Tick is the number of steps played so far.
+func (p *Player) Tick() int {
+	return p.tick
+}
+
+// Ticks is the length of the replay.
+func (p *Player) Ticks() int {
+	return p.ticks
+}
+
+func (p *Player) Done() bool {
+	return p.tick >= p.ticks
+}
+
+// Step plays the next tick, returning its events.
+func (p *Player) Step() []sim.Event {
+	if p.Done() {
+		return nil
+	}
+	run := p.Replay.Inputs[p.run]
+	if p.inRun == 0 && run.Jump != nil {
+		p.State = *run.Jump
+	}
+	var events []sim.Event
+	p.State, events = sim.Step(p.State, run.Inputs)
+	p.tick++
+	p.inRun++
+	if p.inRun == run.Count {
+		p.run++
+		p.inRun = 0
+	}
+	if p.tick%keyframeInterval == 0 && p.tick/keyframeInterval == len(p.keyframes) {
+		p.keyframes = append(p.keyframes, p.State)
+	}
+	return events
+}
+
+// Seek moves to the given tick, clamped to the replay's length.
+func (p *Player) Seek(tick int) {
+	if tick < 0 {
+		tick = 0
+	}
+	if tick > p.ticks {
+		tick = p.ticks
+	}
+	k := tick / keyframeInterval
+	if k >= len(p.keyframes) {
+		k = len(p.keyframes) - 1
+	}
+	if tick < p.tick || k*keyframeInterval > p.tick {
+		p.State = p.keyframes[k]
+		p.tick = k * keyframeInterval
+		p.findRun()
+	}
+	for p.tick < tick {
+		p.Step()
+	}
+}
+
+// findRun points the input cursor at the current tick.
+func (p *Player) findRun() {
+	left := p.tick
+	for p.run = 0; p.run < len(p.Replay.Inputs); p.run++ {
+		if left < p.Replay.Inputs[p.run].Count {
+			p.inRun = left
+			return
+		}
+		left -= p.Replay.Inputs[p.run].Count
+	}
+	p.inRun = 0
+}
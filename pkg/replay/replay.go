@@ -0,0 +1,117 @@
+// Package replay records the inputs of a match so it can be played back
+// exactly. The simulation is deterministic, so the starting state and the
+// inputs of every tick are all a replay needs, along with any states the
+// session jumped to, such as when a snapshot was loaded.
+package replay
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// Version is bumped whenever a change to the simulation would make old
+// replays play out differently.
+const Version = 6
+
+// Replay is a recorded match. Inputs are run-length encoded since players
+// hold the same keys for many ticks at a time.
+type Replay struct {
+	Version int
+	Seed    int64
+	Config  sim.Config
+	Initial sim.State
+	Inputs  []Run
+}
+
+type Run struct {
+	Count  int
+	Inputs sim.Inputs
+	// Jump, if set, replaces the state before the run's first tick.
+	Jump *sim.State `json:",omitempty"`
+}
+
+// Ticks is the length of the replay in simulation steps.
+func (r *Replay) Ticks() int {
+	n := 0
+	for _, run := range r.Inputs {
+		n += run.Count
+	}
+	return n
+}
+
+type Recorder struct {
+	replay Replay
+	jump   *sim.State
+}
+
+func NewRecorder(initial sim.State, seed int64) *Recorder {
+	return &Recorder{replay: Replay{
+		Version: Version,
+		Seed:    seed,
+		Config:  initial.Config,
+		Initial: initial,
+	}}
+}
+
+// Record adds the inputs of the next tick.
+func (r *Recorder) Record(in sim.Inputs) {
+	runs := r.replay.Inputs
+	if n := len(runs); n > 0 && runs[n-1].Inputs == in && r.jump == nil {
+		runs[n-1].Count++
+		return
+	}
+	r.replay.Inputs = append(runs, Run{Count: 1, Inputs: in, Jump: r.jump})
+	r.jump = nil
+}
+
+// Jump records the session carrying on from s instead of from where the
+// last tick left it, as when a snapshot is loaded.
+func (r *Recorder) Jump(s sim.State) {
+	r.jump = &s
+}
+
+// Replay returns what has been recorded so far.
+func (r *Recorder) Replay() *Replay {
+	return &r.replay
+}
+
+// Save writes the replay as gzipped JSON.
+func Save(path string, r *Replay) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	if err := json.NewEncoder(zw).Encode(r); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func Load(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	var r Replay
+	if err := json.NewDecoder(zr).Decode(&r); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if r.Version != Version {
+		return nil, fmt.Errorf("%s: replay version %d, this build plays version %d", path, r.Version, Version)
+	}
+	return &r, nil
+}
@@ -0,0 +1,119 @@
+package replay
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/fabianvf/pong-golang/pkg/control"
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+const (
+	matchTicks = sim.TickRate * 60
+	// The state saved at saveTick is gone back to at loadTick, the way a
+	// quicksave and quickload would.
+	saveTick = 3000
+	loadTick = 4500
+)
+
+// checkTicks are where seeking is checked against the recorded match,
+// either side of keyframes and the jump back.
+var checkTicks = []int{0, 1, keyframeInterval - 1, keyframeInterval, keyframeInterval + 1, saveTick, loadTick, loadTick + 1, matchTicks - 1, matchTicks}
+
+// play runs a match between two computer players, with the start and pause
+// buttons pressed now and then, recording it as it goes. It returns the
+// states at checkTicks.
+func play(t *testing.T, seed int64) (*Recorder, map[int]sim.State) {
+	s := sim.NewState(sim.DefaultConfig(), seed)
+	r := NewRecorder(s, seed)
+	left, right := control.NewAI(control.Hard, seed), control.NewAI(control.Normal, seed+1)
+	states := map[int]sim.State{0: s}
+	var saved sim.State
+	for tick := 1; tick <= matchTicks; tick++ {
+		switch tick {
+		case saveTick:
+			saved = s
+		case loadTick + 1:
+			s = saved
+			r.Jump(s)
+		}
+		in := sim.Inputs{
+			sim.Left:  left.Intent(&s, sim.Left),
+			sim.Right: right.Intent(&s, sim.Right),
+		}
+		switch {
+		case tick%600 < 5:
+			in[sim.Left].Buttons |= sim.ButtonStart
+		case tick%1700 < 3:
+			in[sim.Right].Buttons |= sim.ButtonPause
+		}
+		r.Record(in)
+		s, _ = sim.Step(s, in)
+		for _, c := range checkTicks {
+			if c == tick {
+				states[tick] = s
+			}
+		}
+	}
+	if s.Stats.Ticks == 0 {
+		t.Fatal("the match never started")
+	}
+	return r, states
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for seed := int64(1); seed <= 3; seed++ {
+		r, states := play(t, seed)
+		// Held inputs, such as both paddles idle during serves, should
+		// share runs.
+		if runs := len(r.Replay().Inputs); runs >= matchTicks {
+			t.Errorf("seed %d: %d runs for %d ticks", seed, runs, matchTicks)
+		}
+
+		path := filepath.Join(dir, "match.replay")
+		if err := Save(path, r.Replay()); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.Ticks() != matchTicks {
+			t.Fatalf("seed %d: %d ticks loaded, want %d", seed, loaded.Ticks(), matchTicks)
+		}
+
+		p := NewPlayer(loaded)
+		for !p.Done() {
+			p.Step()
+		}
+		if !reflect.DeepEqual(p.State, states[matchTicks]) {
+			t.Errorf("seed %d: replay ended differently to the match", seed)
+		}
+
+		// Seek both ways, starting from a fresh player that has no
+		// keyframes past the start yet.
+		p = NewPlayer(loaded)
+		order := append([]int{matchTicks / 2}, checkTicks...)
+		for i := len(checkTicks) - 1; i >= 0; i-- {
+			order = append(order, checkTicks[i])
+		}
+		for _, tick := range order {
+			p.Seek(tick)
+			if p.Tick() != tick {
+				t.Fatalf("seed %d: seeking to %d got to %d", seed, tick, p.Tick())
+			}
+			if want, ok := states[tick]; ok && !reflect.DeepEqual(p.State, want) {
+				t.Errorf("seed %d: state after seeking to %d differs", seed, tick)
+			}
+		}
+	}
+}
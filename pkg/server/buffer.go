@@ -0,0 +1,113 @@
+package server
+
+import (
+	"time"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// keepSnapshots is how many of the latest snapshots a Buffer holds on to.
+const keepSnapshots = 8
+
+// Buffer holds the latest snapshots received and works out what to draw
+// between them. It is not safe for concurrent use.
+type Buffer struct {
+	// Delay is how many ticks behind the latest snapshot the match is
+	// drawn, so there is nearly always a snapshot on either side of the
+	// moment drawn to interpolate between.
+	Delay float64
+
+	snapshots []received
+	events    []sim.Event
+}
+
+type received struct {
+	Snapshot
+	at time.Time
+}
+
+// Add adds a snapshot received at the given time. Snapshots older than the
+// latest are ignored.
+func (b *Buffer) Add(s Snapshot, at time.Time) bool {
+	if n := len(b.snapshots); n > 0 && b.snapshots[n-1].Tick >= s.Tick {
+		return false
+	}
+	b.snapshots = append(b.snapshots, received{Snapshot: s, at: at})
+	b.events = append(b.events, s.Events...)
+	if len(b.snapshots) > keepSnapshots {
+		b.snapshots = b.snapshots[1:]
+	}
+	return true
+}
+
+// Latest returns the newest snapshot, if there is one.
+func (b *Buffer) Latest() (Snapshot, bool) {
+	if len(b.snapshots) == 0 {
+		return Snapshot{}, false
+	}
+	return b.snapshots[len(b.snapshots)-1].Snapshot, true
+}
+
+// Events returns the events received since the last call.
+func (b *Buffer) Events() []sim.Event {
+	events := b.events
+	b.events = nil
+	return events
+}
+
+// State returns the match as it should be drawn at now, or false if no
+// snapshot has arrived yet.
+func (b *Buffer) State(now time.Time) (sim.State, bool) {
+	n := len(b.snapshots)
+	if n == 0 {
+		return sim.State{}, false
+	}
+	latest := b.snapshots[n-1]
+	tick := float64(latest.Tick) + now.Sub(latest.at).Seconds()*sim.TickRate - b.Delay
+
+	from, to := b.snapshots[0], b.snapshots[0]
+	for i, s := range b.snapshots {
+		if float64(s.Tick) > tick {
+			break
+		}
+		from, to = s, s
+		if i+1 < n {
+			to = b.snapshots[i+1]
+		}
+	}
+	state := from.State
+	// Nothing is interpolated across a point being scored or the game
+	// being started, since the ball jumps back to the middle.
+	if to.Tick > from.Tick && from.State.Mode == to.State.Mode && from.State.Score == to.State.Score {
+		f := clamp((tick-float64(from.Tick))/float64(to.Tick-from.Tick), 0, 1)
+		for i := range state.Balls {
+			ball, a, b := &state.Balls[i], &from.State.Balls[i], &to.State.Balls[i]
+			// A ball that comes or goes between snapshots just appears or
+			// disappears.
+			if a.Live && b.Live {
+				ball.Coord.X = lerp(a.Coord.X, b.Coord.X, f)
+				ball.Coord.Y = lerp(a.Coord.Y, b.Coord.Y, f)
+			}
+		}
+		for side := sim.Side(0); side < sim.MaxSides; side++ {
+			paddle, a, b := state.Paddle(side), from.State.Paddle(side), to.State.Paddle(side)
+			paddle.X = lerp(a.X, b.X, f)
+			paddle.Y = lerp(a.Y, b.Y, f)
+		}
+	}
+	return state, true
+}
+
+func lerp(a, b, f float64) float64 {
+	return a + (b-a)*f
+}
+
+func clamp(x, min, max float64) float64 {
+	if x < min {
+		return min
+	}
+	if x > max {
+		return max
+	}
+	return x
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+// ticks is how long n ticks take.
+func ticks(n float64) time.Duration {
+	return time.Duration(n * float64(time.Second) / sim.TickRate)
+}
+
+func snapshot(tick int, ballX, paddleY float64) Snapshot {
+	s := sim.NewState(sim.DefaultConfig(), 1)
+	s.Mode = sim.ModePlay
+	s.Balls[0] = sim.Ball{Live: true, Coord: sim.Pair{X: ballX, Y: 1}}
+	s.LeftPaddle.Y = paddleY
+	return Snapshot{Tick: tick, State: s}
+}
+
+func TestBufferState(t *testing.T) {
+	start := time.Now()
+	scored := snapshot(20, 2, 1)
+	scored.State.Score[sim.Left]++
+	gone := snapshot(20, 2, 1)
+	gone.State.Balls[0].Live = false
+
+	tests := []struct {
+		name string
+		// second arrives ten ticks after the first, which is at tick 10
+		// with the ball at 1 and the paddle at 0.5.
+		second Snapshot
+		// after is how many ticks after the second snapshot arrived the
+		// match is drawn, ten ticks behind.
+		after   float64
+		ballX   float64
+		paddleY float64
+	}{
+		{"first", snapshot(20, 2, 1), 0, 1, 0.5},
+		{"halfway", snapshot(20, 2, 1), 5, 1.5, 0.75},
+		{"quarter", snapshot(20, 2, 1), 2.5, 1.25, 0.625},
+		{"second", snapshot(20, 2, 1), 10, 2, 1},
+		{"past the latest", snapshot(20, 2, 1), 40, 2, 1},
+		{"before the first", snapshot(20, 2, 1), -30, 1, 0.5},
+		{"point scored", scored, 5, 1, 0.5},
+		{"ball gone", gone, 5, 1, 0.75},
+	}
+	for _, test := range tests {
+		b := Buffer{Delay: 10}
+		if _, ok := b.State(start); ok {
+			t.Fatal("state with no snapshots")
+		}
+		b.Add(snapshot(10, 1, 0.5), start)
+		b.Add(test.second, start.Add(ticks(10)))
+		s, ok := b.State(start.Add(ticks(10 + test.after)))
+		if !ok {
+			t.Fatalf("%s: no state", test.name)
+		}
+		if x := s.Balls[0].Coord.X; !near(x, test.ballX) {
+			t.Errorf("%s: ball at %g, want %g", test.name, x, test.ballX)
+		}
+		if y := s.LeftPaddle.Y; !near(y, test.paddleY) {
+			t.Errorf("%s: paddle at %g, want %g", test.name, y, test.paddleY)
+		}
+	}
+}
+
+func TestBufferAdd(t *testing.T) {
+	start := time.Now()
+	var b Buffer
+	for tick := 1; tick <= keepSnapshots+3; tick++ {
+		s := snapshot(tick, 1, 1)
+		s.Events = []sim.Event{{Kind: sim.EventPaddleHit}}
+		if !b.Add(s, start) {
+			t.Errorf("snapshot %d not added", tick)
+		}
+	}
+	if b.Add(snapshot(5, 1, 1), start) {
+		t.Error("added a snapshot older than the latest")
+	}
+	if len(b.snapshots) != keepSnapshots {
+		t.Errorf("holding %d snapshots, want %d", len(b.snapshots), keepSnapshots)
+	}
+	if latest, _ := b.Latest(); latest.Tick != keepSnapshots+3 {
+		t.Errorf("latest snapshot is for tick %d", latest.Tick)
+	}
+	if n := len(b.Events()); n != keepSnapshots+3 {
+		t.Errorf("%d events, want one per snapshot", n)
+	}
+	if n := len(b.Events()); n != 0 {
+		t.Errorf("%d events again", n)
+	}
+}
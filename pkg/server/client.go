@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+const (
+	// InterpolationDelay is how many ticks behind the server clients draw
+	// the ball and the other paddle: three snapshots' worth.
+	InterpolationDelay = 3 * sim.TickRate / SnapshotRate
+	// maxPending bounds the inputs a client remembers while waiting for the
+	// server to acknowledge them.
+	maxPending = sim.TickRate
+)
+
+// Client is a player's end of a match on a Server. The ball and the other
+// paddle are drawn slightly in the past, interpolated between snapshots.
+// The player's own paddle is predicted from the inputs the server hasn't
+// applied yet, so it responds as quickly as it does offline.
+type Client struct {
+	Side   sim.Side
+	Config sim.Config
+
+	conn    Conn
+	mu      sync.Mutex
+	buffer  Buffer
+	pending []ClientMessage
+	seq     int
+	err     error
+}
+
+// Connect asks to join the match on the other end of conn and waits to be
+// welcomed.
+func Connect(conn Conn) (*Client, error) {
+	hello, err := json.Marshal(ClientMessage{})
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.WriteMessage(hello); err != nil {
+		return nil, err
+	}
+	data, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	var m ServerMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if m.Error != "" {
+		return nil, errors.New(m.Error)
+	}
+	if m.Welcome == nil {
+		return nil, errors.New("expected a welcome from the server")
+	}
+	c := &Client{
+		Side:   m.Welcome.Side,
+		Config: m.Welcome.Config,
+		conn:   conn,
+		buffer: Buffer{Delay: InterpolationDelay},
+	}
+	go c.read()
+	return c, nil
+}
+
+// Send sends the player's input for the next tick.
+func (c *Client) Send(in sim.Intent) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.seq++
+	m := ClientMessage{Seq: c.seq, Intent: in}
+	c.pending = append(c.pending, m)
+	if len(c.pending) > maxPending {
+		c.pending = c.pending[1:]
+	}
+	c.mu.Unlock()
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return c.conn.WriteMessage(data)
+}
+
+func (c *Client) read() {
+	for {
+		data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		var m ServerMessage
+		if err := json.Unmarshal(data, &m); err != nil {
+			c.fail(err)
+			return
+		}
+		if m.Error != "" {
+			c.fail(errors.New(m.Error))
+			return
+		}
+		if m.Snapshot != nil {
+			c.receive(*m.Snapshot, time.Now())
+		}
+	}
+}
+
+func (c *Client) receive(s Snapshot, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.buffer.Add(s, at) {
+		return
+	}
+	i := 0
+	for i < len(c.pending) && c.pending[i].Seq <= s.Ack {
+		i++
+	}
+	c.pending = c.pending[i:]
+}
+
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+}
+
+// Events returns the events received since the last call.
+func (c *Client) Events() []sim.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buffer.Events()
+}
+
+// Err returns why the connection to the server was lost, if it was.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// State returns the match as it should be drawn at now.
+func (c *Client) State(now time.Time) sim.State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state, ok := c.buffer.State(now)
+	latest, _ := c.buffer.Latest()
+	if !ok {
+		return sim.NewState(c.Config, 0)
+	}
+
+	own := latest.State
+	if own.Mode == sim.ModePlay {
+		for _, m := range c.pending {
+			own.MovePaddle(c.Side, m.Intent, sim.Dt)
+		}
+	}
+	*state.Paddle(c.Side) = *own.Paddle(c.Side)
+	return state
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/fabianvf/pong-golang/pkg/ws"
+)
+
+// Conn carries whole messages between a client and the server. Native
+// clients use a plain TCP connection with one message per line, browsers a
+// WebSocket. Writes may come from several goroutines, reads from only one.
+type Conn interface {
+	ReadMessage() ([]byte, error)
+	WriteMessage(p []byte) error
+	Close() error
+}
+
+type lineConn struct {
+	rw io.ReadWriteCloser
+	r  *bufio.Reader
+	mu sync.Mutex
+}
+
+// NewLineConn sends one message per line over rw. Messages must not
+// contain newlines, which JSON never does.
+func NewLineConn(rw io.ReadWriteCloser) Conn {
+	return &lineConn{rw: rw, r: bufio.NewReader(rw)}
+}
+
+func (c *lineConn) ReadMessage() ([]byte, error) {
+	line, err := c.r.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+func (c *lineConn) WriteMessage(p []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.rw.Write(append(p, '\n'))
+	return err
+}
+
+func (c *lineConn) Close() error {
+	return c.rw.Close()
+}
+
+// accept works out which kind of client is on the other end of conn: a
+// browser opens with an HTTP request for the WebSocket upgrade, anything
+// else is taken to be a native client.
+func accept(conn net.Conn) (Conn, error) {
+	r := bufio.NewReader(conn)
+	head, err := r.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+	if string(head) == "GET " {
+		c, err := ws.Upgrade(conn, r)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	return &lineConn{rw: conn, r: r}, nil
+}
@@ -0,0 +1,35 @@
+package server
+
+import "github.com/fabianvf/pong-golang/pkg/sim"
+
+// ClientMessage is sent by a client once per tick. Seq counts up from one so
+// the server can say which inputs it has used. Clients open with a message
+// with Seq zero, since the server waits to hear from them to tell browsers
+// from native clients.
+type ClientMessage struct {
+	Seq    int
+	Intent sim.Intent
+}
+
+// ServerMessage carries exactly one of its fields.
+type ServerMessage struct {
+	Welcome  *Welcome  `json:",omitempty"`
+	Snapshot *Snapshot `json:",omitempty"`
+	Error    string    `json:",omitempty"`
+}
+
+// Welcome tells a new client which paddle is theirs.
+type Welcome struct {
+	Side   sim.Side
+	Config sim.Config
+}
+
+// Snapshot is the state of the match after Tick ticks. Ack is the Seq of the
+// last input of the receiving client that has been applied. Events are the
+// ones from the ticks since the previous snapshot.
+type Snapshot struct {
+	Tick   int
+	Ack    int
+	State  sim.State
+	Events []sim.Event `json:",omitempty"`
+}
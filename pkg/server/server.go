@@ -0,0 +1,237 @@
+// Package server runs matches on a dedicated server that owns the
+// simulation. Clients only send their input and draw the snapshots they are
+// sent, so no client can cheat or fall out of sync; see Client for how they
+// hide the round trip.
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+const (
+	// SnapshotRate is how many snapshots a second clients are sent.
+	SnapshotRate = 30
+	// maxQueued bounds the inputs held for a client. If more arrive the
+	// client is running fast or the connection bunched them up, and the
+	// oldest are dropped so its paddle doesn't lag behind.
+	maxQueued = 8
+	// sendQueue is how many messages may wait for a slow client before
+	// newer ones are dropped.
+	sendQueue = 16
+)
+
+// Server plays one match at a time between the first two clients to
+// connect. When a player leaves their paddle stands still until someone
+// takes it over.
+type Server struct {
+	Config sim.Config
+
+	mu      sync.Mutex
+	state   sim.State
+	tick    int
+	events  []sim.Event
+	players [2]*player
+}
+
+type player struct {
+	conn  Conn
+	send  chan []byte
+	queue []ClientMessage
+	last  sim.Intent
+	ack   int
+}
+
+func New(config sim.Config, seed int64) *Server {
+	return &Server{Config: config, state: sim.NewState(config, seed)}
+}
+
+// ListenAndServe accepts native and browser clients on addr and runs the
+// match until the listener fails.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("server listening on %s", l.Addr())
+	return s.Serve(l)
+}
+
+func (s *Server) Serve(l net.Listener) error {
+	defer l.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.run(stop)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			c, err := accept(conn)
+			if err != nil {
+				log.Printf("%s: %v", conn.RemoteAddr(), err)
+				conn.Close()
+				return
+			}
+			s.handle(c)
+		}()
+	}
+}
+
+func (s *Server) handle(c Conn) {
+	defer c.Close()
+	p := &player{conn: c, send: make(chan []byte, sendQueue)}
+	side, ok := s.join(p)
+	if !ok {
+		writeMessage(c, ServerMessage{Error: "the match is full"})
+		return
+	}
+	defer s.leave(side)
+	go p.write()
+	p.post(ServerMessage{Welcome: &Welcome{Side: side, Config: s.Config}})
+
+	for {
+		data, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		var m ClientMessage
+		if err := json.Unmarshal(data, &m); err != nil {
+			p.post(ServerMessage{Error: err.Error()})
+			return
+		}
+		if m.Seq == 0 {
+			continue
+		}
+		s.mu.Lock()
+		p.queue = append(p.queue, m)
+		s.mu.Unlock()
+	}
+}
+
+func (s *Server) join(p *player) (sim.Side, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for side, other := range s.players {
+		if other == nil {
+			s.players[side] = p
+			return sim.Side(side), true
+		}
+	}
+	return 0, false
+}
+
+// leave frees the player's paddle, pausing the match until someone takes
+// it over and presses start.
+func (s *Server) leave(side sim.Side) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	close(s.players[side].send)
+	s.players[side] = nil
+	if s.state.Mode == sim.ModePlay {
+		s.state.Mode = sim.ModePause
+	}
+}
+
+// run steps the match at sim.TickRate.
+func (s *Server) run(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second / sim.TickRate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.step()
+		}
+	}
+}
+
+func (s *Server) step() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var inputs sim.Inputs
+	full := true
+	for side, p := range s.players {
+		if p == nil {
+			full = false
+			continue
+		}
+		inputs[side] = p.next()
+	}
+	if !full {
+		// Nobody can start a match on their own.
+		for side := range inputs {
+			inputs[side].Buttons &^= sim.ButtonStart
+		}
+	}
+	var events []sim.Event
+	s.state, events = sim.Step(s.state, inputs)
+	s.events = append(s.events, events...)
+	s.tick++
+
+	if s.tick%(sim.TickRate/SnapshotRate) != 0 {
+		return
+	}
+	for _, p := range s.players {
+		if p != nil {
+			p.post(ServerMessage{Snapshot: &Snapshot{Tick: s.tick, Ack: p.ack, State: s.state, Events: s.events}})
+		}
+	}
+	s.events = nil
+}
+
+// next takes the player's input for this tick. If none has arrived their
+// last one is used again.
+func (p *player) next() sim.Intent {
+	if n := len(p.queue) - maxQueued; n > 0 {
+		// Presses in the dropped inputs still count.
+		var buttons sim.Buttons
+		for _, m := range p.queue[:n] {
+			buttons |= m.Intent.Buttons
+		}
+		p.queue = p.queue[n:]
+		p.queue[0].Intent.Buttons |= buttons
+	}
+	if len(p.queue) > 0 {
+		m := p.queue[0]
+		p.queue = p.queue[1:]
+		p.last, p.ack = m.Intent, m.Seq
+	}
+	return p.last
+}
+
+// post queues m for the player, dropping it if they aren't keeping up.
+func (p *player) post(m ServerMessage) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	select {
+	case p.send <- data:
+	default:
+	}
+}
+
+func (p *player) write() {
+	for data := range p.send {
+		if err := p.conn.WriteMessage(data); err != nil {
+			p.conn.Close()
+		}
+	}
+}
+
+func writeMessage(c Conn, m ServerMessage) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(data)
+}
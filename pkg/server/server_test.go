@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// connect joins s over an in-memory connection.
+func connect(t *testing.T, s *Server) (*Client, error) {
+	t.Helper()
+	a, b := net.Pipe()
+	go s.handle(NewLineConn(b))
+	return Connect(NewLineConn(a))
+}
+
+func TestServerMatch(t *testing.T) {
+	s := New(sim.DefaultConfig(), 3)
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.run(stop)
+
+	var clients [2]*Client
+	for i := range clients {
+		c, err := connect(t, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		if c.Side != sim.Side(i) {
+			t.Errorf("client %d got side %d", i, c.Side)
+		}
+		clients[i] = c
+	}
+	// The server turns a third away before it has said anything.
+	a, b := net.Pipe()
+	go s.handle(NewLineConn(b))
+	third := NewLineConn(a)
+	data, err := third.ReadMessage()
+	third.Close()
+	if err != nil || !strings.Contains(string(data), "full") {
+		t.Errorf("third client told %q, %v", data, err)
+	}
+
+	// Both press start, then the left player moves up and the right down.
+	deadline := time.Now().Add(5 * time.Second)
+	for tick := 0; ; tick++ {
+		if time.Now().After(deadline) {
+			t.Fatal("the paddles never got anywhere")
+		}
+		for i, c := range clients {
+			in := sim.Intent{Axis: float64(2*i - 1)}
+			if tick < 5 {
+				in = sim.Intent{Buttons: sim.ButtonStart}
+			}
+			if err := c.Send(in); err != nil {
+				t.Fatal(err)
+			}
+		}
+		time.Sleep(time.Second / sim.TickRate)
+
+		left, right := clients[0].State(time.Now()), clients[1].State(time.Now())
+		if left.Mode == sim.ModePlay && right.Mode == sim.ModePlay &&
+			left.LeftPaddle.Y == 0 && near(right.RightPaddle.Y+right.RightPaddle.H, sim.ArenaHeight) {
+			break
+		}
+	}
+
+	// The server agrees with what the clients predicted for themselves.
+	s.mu.Lock()
+	state := s.state
+	s.mu.Unlock()
+	if state.LeftPaddle.Y != 0 || !near(state.RightPaddle.Y+state.RightPaddle.H, sim.ArenaHeight) {
+		t.Errorf("server has the paddles at %g and %g", state.LeftPaddle.Y, state.RightPaddle.Y)
+	}
+	for i, c := range clients {
+		if err := c.Err(); err != nil {
+			t.Errorf("client %d: %v", i, err)
+		}
+		c.mu.Lock()
+		latest, _ := c.buffer.Latest()
+		c.mu.Unlock()
+		if latest.Ack == 0 {
+			t.Errorf("client %d had no input acknowledged", i)
+		}
+	}
+}
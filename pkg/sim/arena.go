@@ -0,0 +1,215 @@
+package sim
+
+import (
+	"math"
+)
+
+type Shape int
+
+const (
+	// ShapeNone marks an unused obstacle slot.
+	ShapeNone Shape = iota
+	ShapeRect
+	ShapeCircle
+)
+
+const (
+	MaxObstacles = 16
+	MaxSpawns    = 8
+)
+
+// Obstacle is something in the arena balls bounce off. A rectangle is
+// given by Rect and a circle by Center and Radius.
+type Obstacle struct {
+	Shape  Shape
+	Rect   Rect
+	Center Pair
+	Radius float64
+	// Restitution scales a ball's speed as it bounces off. Bumpers have
+	// more than 1 and kick the ball away faster. Zero means 1.
+	Restitution float64
+	// Move is how far a moving obstacle travels from where it starts
+	// before heading back, and Period is the seconds the round trip takes.
+	// Obstacles with no Period stay put.
+	Move   Pair
+	Period float64
+}
+
+// At is where the obstacle is after ticks of play.
+func (o Obstacle) At(ticks int) Obstacle {
+	if o.Period <= 0 {
+		return o
+	}
+	phase := math.Mod(float64(ticks)*Dt/o.Period, 1)
+	f := 1 - math.Abs(1-2*phase)
+	o.Rect.X += o.Move.X * f
+	o.Rect.Y += o.Move.Y * f
+	o.Center.X += o.Move.X * f
+	o.Center.Y += o.Move.Y * f
+	return o
+}
+
+// sweep returns when a ball moving by delta touches the obstacle.
+func (o *Obstacle) sweep(center Pair, radius float64, delta Pair) (Hit, bool) {
+	switch o.Shape {
+	case ShapeRect:
+		return SweepCircleRect(center, radius, delta, o.Rect)
+	case ShapeCircle:
+		return SweepCircleCircle(center, radius, delta, o.Center, o.Radius)
+	}
+	return Hit{}, false
+}
+
+// Overlaps reports whether a circle at center overlaps the obstacle.
+func (o *Obstacle) Overlaps(center Pair, radius float64) bool {
+	_, ok := o.sweep(center, radius, Pair{})
+	return ok
+}
+
+// Arena is the layout a match is played in. The zero Arena is the classic
+// empty rectangle.
+type Arena struct {
+	Obstacles [MaxObstacles]Obstacle
+	// Goals are how wide each side's goal is, as a fraction of its wall,
+	// centred on it. The rest of the wall is solid. Zero is the whole wall.
+	Goals [MaxSides]float64
+	// Balls come into play from one of the first NumSpawns Spawns, picked
+	// at random, or from the middle of the arena if there are none.
+	Spawns    [MaxSpawns]Pair
+	NumSpawns int
+}
+
+// Mouth is the stretch of side's wall, from lo to hi along it, that is its
+// goal.
+func (a *Arena) Mouth(side Side) (lo, hi float64) {
+	length := ArenaHeight
+	if side.Horizontal() {
+		length = ArenaWidth
+	}
+	size := a.Goals[side]
+	if size <= 0 || size >= 1 {
+		return 0, length
+	}
+	return length * (1 - size) / 2, length * (1 + size) / 2
+}
+
+// posts are the solid parts of side's wall either side of its goal, as
+// blocks just outside the arena.
+func (a *Arena) posts(side Side) [2]Rect {
+	const depth = 1.0
+	lo, hi := a.Mouth(side)
+	switch side {
+	case Right:
+		return [2]Rect{{X: ArenaWidth, W: depth, H: lo}, {X: ArenaWidth, Y: hi, W: depth, H: ArenaHeight - hi}}
+	case Top:
+		return [2]Rect{{Y: -depth, W: lo, H: depth}, {X: hi, Y: -depth, W: ArenaWidth - hi, H: depth}}
+	case Bottom:
+		return [2]Rect{{Y: ArenaHeight, W: lo, H: depth}, {X: hi, Y: ArenaHeight, W: ArenaWidth - hi, H: depth}}
+	}
+	return [2]Rect{{X: -depth, W: depth, H: lo}, {X: -depth, Y: hi, W: depth, H: ArenaHeight - hi}}
+}
+
+// spawnPoint is where the next ball comes into play.
+func (s *State) spawnPoint() Pair {
+	a := &s.Config.Arena
+	switch {
+	case a.NumSpawns <= 0:
+		return Pair{X: ArenaWidth / 2, Y: ArenaHeight / 2}
+	case a.NumSpawns == 1:
+		return a.Spawns[0]
+	}
+	n := a.NumSpawns
+	if n > MaxSpawns {
+		n = MaxSpawns
+	}
+	return a.Spawns[s.Rand.Uint64()%uint64(n)]
+}
+
+// clear reports whether a circle at center is clear of every obstacle as
+// they are now.
+func (s *State) clear(center Pair, radius float64) bool {
+	for _, o := range s.Config.Arena.Obstacles {
+		if o.Shape == ShapeNone {
+			continue
+		}
+		if o = o.At(s.Stats.Ticks); o.Overlaps(center, radius) {
+			return false
+		}
+	}
+	return true
+}
+
+// bounceObstacle sends b off obstacle o, faster or slower by its
+// restitution but never below the ball's base speed or above its top speed.
+func (s *State) bounceObstacle(b *Ball, o *Obstacle, normal Pair) {
+	b.Velocity = Reflect(b.Velocity, normal)
+	if r := o.Restitution; r != 0 && r != 1 {
+		speed := math.Hypot(b.Velocity.X, b.Velocity.Y)
+		target := math.Max(b.BaseSpeed, math.Min(speed*r, b.VelocityBounds.Y))
+		if speed > 0 {
+			b.Velocity.X *= target / speed
+			b.Velocity.Y *= target / speed
+		}
+	}
+	s.keepCrossing(b)
+}
+
+// pushOut moves balls out of the way of moving obstacles, which would
+// otherwise swallow them, and turns them back if they were heading in.
+func (s *State) pushOut() {
+	for i := range s.Config.Arena.Obstacles {
+		o := s.Config.Arena.Obstacles[i]
+		if o.Shape == ShapeNone || o.Period <= 0 {
+			continue
+		}
+		o = o.At(s.Stats.Ticks)
+		for j := range s.Balls {
+			b := &s.Balls[j]
+			if !b.Live || b.Stuck > 0 {
+				continue
+			}
+			hit, ok := o.sweep(b.Coord, b.Radius, Pair{})
+			if !ok {
+				continue
+			}
+			b.Coord = o.surface(b.Coord, b.Radius, hit.Normal)
+			if b.Velocity.Dot(hit.Normal) < 0 {
+				s.bounceObstacle(b, &o, hit.Normal)
+			}
+		}
+	}
+}
+
+// surface is where a circle pushed out of the obstacle along normal comes
+// to rest against it.
+func (o *Obstacle) surface(center Pair, radius float64, normal Pair) Pair {
+	if o.Shape == ShapeCircle {
+		d := o.Radius + radius
+		return Pair{X: o.Center.X + normal.X*d, Y: o.Center.Y + normal.Y*d}
+	}
+	r := o.Rect
+	switch {
+	case normal.X < 0:
+		center.X = r.X - radius
+	case normal.X > 0:
+		center.X = r.X + r.W + radius
+	}
+	switch {
+	case normal.Y < 0:
+		center.Y = r.Y - radius
+	case normal.Y > 0:
+		center.Y = r.Y + r.H + radius
+	}
+	if normal.X != 0 && normal.Y != 0 {
+		// Off a corner: rest against the corner along the normal.
+		corner := Pair{X: r.X, Y: r.Y}
+		if normal.X > 0 {
+			corner.X += r.W
+		}
+		if normal.Y > 0 {
+			corner.Y += r.H
+		}
+		return Pair{X: corner.X + normal.X*radius, Y: corner.Y + normal.Y*radius}
+	}
+	return center
+}
@@ -0,0 +1,39 @@
+package sim
+
+// TickRate is the fixed number of simulation steps per second. Every speed in
+// Config is expressed per second and integrated over Dt, so a match plays the
+// same no matter how often the renderer calls in.
+const TickRate = 120
+
+const Dt = 1.0 / TickRate
+
+// maxStepsPerAdvance bounds how far the clock will catch up after a stall, so
+// a slow frame can't snowball into ever longer frames.
+const maxStepsPerAdvance = TickRate / 4
+
+// Clock converts variable frame times into a whole number of fixed steps.
+type Clock struct {
+	accumulator float64
+}
+
+// Advance adds elapsed seconds to the clock and returns how many steps of Dt
+// should be simulated now.
+func (c *Clock) Advance(elapsed float64) int {
+	c.accumulator += elapsed
+	steps := 0
+	for c.accumulator >= Dt {
+		c.accumulator -= Dt
+		steps++
+		if steps == maxStepsPerAdvance {
+			c.accumulator = 0
+			break
+		}
+	}
+	return steps
+}
+
+// Alpha is how far between the last and the next step the clock currently is,
+// for renderers that interpolate.
+func (c *Clock) Alpha() float64 {
+	return c.accumulator / Dt
+}
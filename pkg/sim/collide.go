@@ -0,0 +1,184 @@
+package sim
+
+import (
+	"math"
+)
+
+// Rect is an axis aligned rectangle with its top left corner at X, Y.
+type Rect struct {
+	X float64
+	Y float64
+	W float64
+	H float64
+}
+
+func (r *Rect) Center() Pair {
+	return Pair{X: r.X + r.W/2, Y: r.Y + r.H/2}
+}
+
+// Transposed swaps the rectangle's axes; see Pair.Transposed.
+func (r Rect) Transposed() Rect {
+	return Rect{X: r.Y, Y: r.X, W: r.H, H: r.W}
+}
+
+// Hit describes the first contact of a moving circle with a shape. Time is
+// the fraction of the movement completed at the moment of impact and Normal
+// is the unit surface normal at the contact, pointing towards the circle.
+type Hit struct {
+	Time   float64
+	Normal Pair
+}
+
+// SweepCircleRect moves a circle of the given radius from center by delta
+// and reports the first time it touches rect. A circle that already
+// overlaps the rectangle hits at time 0, with the normal pointing along the
+// shortest way out.
+func SweepCircleRect(center Pair, radius float64, delta Pair, rect Rect) (Hit, bool) {
+	if hit, ok := overlapCircleRect(center, radius, rect); ok {
+		return hit, true
+	}
+
+	// Sweeping a circle against a rectangle is the same as casting a ray
+	// from its centre against the rectangle grown by the radius, whose
+	// corners are rounded. Start with the square-cornered box.
+	minX, maxX := rect.X-radius, rect.X+rect.W+radius
+	minY, maxY := rect.Y-radius, rect.Y+rect.H+radius
+
+	tEnter, tExit := math.Inf(-1), math.Inf(1)
+	var normal Pair
+
+	if delta.X == 0 {
+		if center.X < minX || center.X > maxX {
+			return Hit{}, false
+		}
+	} else {
+		t1, t2 := (minX-center.X)/delta.X, (maxX-center.X)/delta.X
+		n := Pair{X: -1}
+		if t1 > t2 {
+			t1, t2 = t2, t1
+			n.X = 1
+		}
+		if t1 > tEnter {
+			tEnter, normal = t1, n
+		}
+		tExit = math.Min(tExit, t2)
+	}
+	if delta.Y == 0 {
+		if center.Y < minY || center.Y > maxY {
+			return Hit{}, false
+		}
+	} else {
+		t1, t2 := (minY-center.Y)/delta.Y, (maxY-center.Y)/delta.Y
+		n := Pair{Y: -1}
+		if t1 > t2 {
+			t1, t2 = t2, t1
+			n.Y = 1
+		}
+		if t1 > tEnter {
+			tEnter, normal = t1, n
+		}
+		tExit = math.Min(tExit, t2)
+	}
+	if tEnter > tExit || tEnter > 1 || tExit < 0 {
+		return Hit{}, false
+	}
+	// Starting inside the grown box without overlapping means the circle is
+	// next to a corner, where the box is wider than the real shape, or is
+	// resting against a face and rounding put it a hair inside.
+	resting := tEnter < 0
+	tEnter = math.Max(tEnter, 0)
+
+	// If the entry point lies beyond the rectangle on both axes the ray went
+	// through a rounded-off corner, and the real contact, if any, is with
+	// the circle at that corner.
+	p := Pair{X: center.X + delta.X*tEnter, Y: center.Y + delta.Y*tEnter}
+	var corner Pair
+	switch {
+	case p.X < rect.X:
+		corner.X = rect.X
+	case p.X > rect.X+rect.W:
+		corner.X = rect.X + rect.W
+	default:
+		if resting {
+			return Hit{}, false
+		}
+		return Hit{Time: tEnter, Normal: normal}, true
+	}
+	switch {
+	case p.Y < rect.Y:
+		corner.Y = rect.Y
+	case p.Y > rect.Y+rect.H:
+		corner.Y = rect.Y + rect.H
+	default:
+		if resting {
+			return Hit{}, false
+		}
+		return Hit{Time: tEnter, Normal: normal}, true
+	}
+	return sweepCirclePoint(center, radius, delta, corner)
+}
+
+// SweepCircleCircle is SweepCircleRect for a circle of otherRadius
+// centred on other.
+func SweepCircleCircle(center Pair, radius float64, delta Pair, other Pair, otherRadius float64) (Hit, bool) {
+	dx, dy := center.X-other.X, center.Y-other.Y
+	d := math.Hypot(dx, dy)
+	if d < radius+otherRadius {
+		if d == 0 {
+			return Hit{Normal: Pair{Y: -1}}, true
+		}
+		return Hit{Normal: Pair{X: dx / d, Y: dy / d}}, true
+	}
+	return sweepCirclePoint(center, radius+otherRadius, delta, other)
+}
+
+func sweepCirclePoint(center Pair, radius float64, delta Pair, point Pair) (Hit, bool) {
+	fx, fy := center.X-point.X, center.Y-point.Y
+	a := delta.X*delta.X + delta.Y*delta.Y
+	b := 2 * (fx*delta.X + fy*delta.Y)
+	c := fx*fx + fy*fy - radius*radius
+	disc := b*b - 4*a*c
+	if a == 0 || disc < 0 {
+		return Hit{}, false
+	}
+	t := (-b - math.Sqrt(disc)) / (2 * a)
+	if t < 0 || t > 1 {
+		return Hit{}, false
+	}
+	n := Pair{X: fx + delta.X*t, Y: fy + delta.Y*t}
+	l := math.Hypot(n.X, n.Y)
+	return Hit{Time: t, Normal: Pair{X: n.X / l, Y: n.Y / l}}, true
+}
+
+func overlapCircleRect(center Pair, radius float64, rect Rect) (Hit, bool) {
+	nx := math.Max(rect.X, math.Min(center.X, rect.X+rect.W))
+	ny := math.Max(rect.Y, math.Min(center.Y, rect.Y+rect.H))
+	dx, dy := center.X-nx, center.Y-ny
+	d := math.Hypot(dx, dy)
+	if d >= radius {
+		return Hit{}, false
+	}
+	if d > 0 {
+		return Hit{Normal: Pair{X: dx / d, Y: dy / d}}, true
+	}
+
+	// The centre is inside the rectangle: leave through the nearest side.
+	left, right := center.X-rect.X, rect.X+rect.W-center.X
+	top, bottom := center.Y-rect.Y, rect.Y+rect.H-center.Y
+	switch math.Min(math.Min(left, right), math.Min(top, bottom)) {
+	case left:
+		return Hit{Normal: Pair{X: -1}}, true
+	case right:
+		return Hit{Normal: Pair{X: 1}}, true
+	case top:
+		return Hit{Normal: Pair{Y: -1}}, true
+	default:
+		return Hit{Normal: Pair{Y: 1}}, true
+	}
+}
+
+// Reflect mirrors v about the surface with unit normal n.
+func Reflect(v, n Pair) Pair {
+	d := 2 * (v.X*n.X + v.Y*n.Y)
+	return Pair{X: v.X - d*n.X, Y: v.Y - d*n.Y}
+}
@@ -0,0 +1,152 @@
+package sim
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSweepCircleRect(t *testing.T) {
+	// A radius exact in binary lets circles rest exactly against a face.
+	const radius = 0.25
+	diagonal := 1 / math.Sqrt2
+	for _, tc := range []struct {
+		name   string
+		center Pair
+		delta  Pair
+		// rect and radius, if set, replace the ones used by the rest.
+		rect   Rect
+		radius float64
+		hit    bool
+		want   Hit
+	}{
+		{
+			name:   "face",
+			center: Pair{X: 0.5, Y: 1.5},
+			delta:  Pair{X: 1},
+			hit:    true,
+			want:   Hit{Time: 0.25, Normal: Pair{X: -1}},
+		},
+		{
+			name:   "corner",
+			center: Pair{X: 0.5, Y: 0.5},
+			delta:  Pair{X: 1, Y: 1},
+			hit:    true,
+			want:   Hit{Time: 0.5 - radius/math.Sqrt2, Normal: Pair{X: -diagonal, Y: -diagonal}},
+		},
+		{
+			// The path crosses the square corner of the rectangle grown by
+			// the radius, but passes the real corner further than the
+			// radius away.
+			name:   "past the corner",
+			center: Pair{X: 0.5, Y: 1.5 - 0.3*math.Sqrt2},
+			delta:  Pair{X: 0.6, Y: -0.6},
+		},
+		{
+			name:   "overlapping",
+			center: Pair{X: 1.05, Y: 0.95},
+			delta:  Pair{X: 1, Y: 1},
+			hit:    true,
+			want:   Hit{Normal: Pair{Y: -1}},
+		},
+		{
+			name:   "resting and moving in",
+			center: Pair{X: 0.75, Y: 1.5},
+			delta:  Pair{X: 0.5},
+			hit:    true,
+			want:   Hit{Normal: Pair{X: -1}},
+		},
+		{
+			name:   "resting and moving away",
+			center: Pair{X: 0.75, Y: 1.5},
+			delta:  Pair{X: -0.5, Y: 0.2},
+		},
+		{
+			// Rounding leaves the centre a hair inside the rectangle grown
+			// by the radius, though not overlapping the rectangle itself.
+			name:   "resting with rounding",
+			center: Pair{X: 1.8666666666666665, Y: 0.8422166497124294},
+			delta:  Pair{X: -0.02, Y: -0.01},
+			rect:   Rect{X: 1.9, Y: 0.5, W: 0.2, H: 0.6},
+			radius: ArenaWidth / 120,
+		},
+		{
+			name:   "miss",
+			center: Pair{X: 0.5, Y: 0.5},
+			delta:  Pair{X: 1},
+		},
+		{
+			name:   "short",
+			center: Pair{X: 0.5, Y: 1.5},
+			delta:  Pair{X: 0.2},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rect, r := Rect{X: 1, Y: 1, W: 1, H: 1}, radius
+			if tc.radius != 0 {
+				rect, r = tc.rect, tc.radius
+			}
+			got, hit := SweepCircleRect(tc.center, r, tc.delta, rect)
+			if hit != tc.hit {
+				t.Fatalf("hit %v, want %v (%+v)", hit, tc.hit, got)
+			}
+			if !hit {
+				return
+			}
+			if !near(got.Time, tc.want.Time) || !near(got.Normal.X, tc.want.Normal.X) || !near(got.Normal.Y, tc.want.Normal.Y) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// TestNoTunnelling fires balls at the left paddle at top speed from several
+// angles, hitting it at different points along it and at different moments
+// within a tick, with the paddle still or moving at full speed, and checks
+// every one comes back.
+func TestNoTunnelling(t *testing.T) {
+	config := DefaultConfig()
+	speed := config.MaxBallSpeed
+	const ticks = 10
+	for _, degrees := range []float64{-60, -45, -20, 0, 20, 45, 60} {
+		for _, along := range []float64{-0.9, -0.5, 0, 0.5, 0.9} {
+			for _, phase := range []float64{0, 0.25, 0.5, 0.75} {
+				for _, axis := range []float64{-1, 0, 1} {
+					s := NewState(config, 1)
+					s.Mode = ModePlay
+					paddle := s.LeftPaddle
+					// Aim for where the paddle will be when the ball gets
+					// there.
+					travel := axis * config.PaddleSpeed * (ticks + phase) * Dt
+					angle := degrees * math.Pi / 180
+					b := &s.Balls[0]
+					b.Velocity = Pair{X: -speed * math.Cos(angle), Y: speed * math.Sin(angle)}
+					target := Pair{X: paddle.X + paddle.W + b.Radius, Y: paddle.Center().Y + travel + along*paddle.H/2}
+					back := (ticks + phase) * Dt
+					b.Coord = Pair{X: target.X - b.Velocity.X*back, Y: target.Y - b.Velocity.Y*back}
+
+					in := Inputs{Left: Intent{Axis: axis}}
+					hit := false
+					for tick := 0; tick < 2*ticks && !hit; tick++ {
+						var events []Event
+						s, events = Step(s, in)
+						for _, e := range events {
+							switch e.Kind {
+							case EventPaddleHit:
+								hit = true
+							case EventGoal:
+								t.Fatalf("%v degrees, %v along, phase %v, axis %v: ball went through the paddle", degrees, along, phase, axis)
+							}
+						}
+					}
+					if !hit || s.Balls[0].Velocity.X <= 0 {
+						t.Errorf("%v degrees, %v along, phase %v, axis %v: ball not returned: %+v", degrees, along, phase, axis, s.Balls[0])
+					}
+				}
+			}
+		}
+	}
+}
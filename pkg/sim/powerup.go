@@ -0,0 +1,310 @@
+package sim
+
+import (
+	"math"
+)
+
+type PowerKind int
+
+const (
+	// PowerGrow lengthens the collector's paddle.
+	PowerGrow PowerKind = iota
+	// PowerShrink shortens every other player's paddle.
+	PowerShrink
+	// PowerSpeed sends balls off the collector's paddle faster.
+	PowerSpeed
+	// PowerSticky catches balls on the collector's paddle until they press
+	// start or StickyHold runs out, so they can aim the return.
+	PowerSticky
+	// PowerSplit splits the ball that collected it in two, straight away.
+	PowerSplit
+	// PowerSlow slows every ball down.
+	PowerSlow
+	// PowerInvisible hides balls last hit by the collector. Only the
+	// renderer acts on it.
+	PowerInvisible
+)
+
+// NumPowers is how many kinds of power-up there are.
+const NumPowers = 7
+
+var powerNames = [NumPowers]string{"grow", "shrink", "speed", "sticky", "split", "slow", "invisible"}
+
+func (k PowerKind) String() string {
+	return powerNames[k]
+}
+
+// PowerByName looks a kind of power-up up by its String.
+func PowerByName(name string) (PowerKind, bool) {
+	for k, n := range powerNames {
+		if n == name {
+			return PowerKind(k), true
+		}
+	}
+	return 0, false
+}
+
+// Stacks reports whether collecting a power-up that is already in effect
+// adds to it, up to MaxStacks. Either way the effect starts its full
+// duration over.
+func (k PowerKind) Stacks() bool {
+	return k == PowerGrow || k == PowerShrink || k == PowerSpeed
+}
+
+const (
+	// MaxPowerUps is the most power-ups waiting in the arena at once.
+	MaxPowerUps = 4
+	MaxStacks   = 3
+	// PowerUpRadius is the size of a power-up, in arena units.
+	PowerUpRadius = ArenaWidth / 50
+	// StickyHold is the most seconds a sticky paddle holds a ball.
+	StickyHold = 1.0
+
+	growStep   = 0.3
+	shrinkStep = 0.2
+	speedStep  = 0.25
+	slowFactor = 0.5
+	// splitAngle is how far apart, in radians, the halves of a split ball
+	// head.
+	splitAngle = math.Pi / 6
+)
+
+// PowerUps are pickups that appear in the arena. A ball that runs into one
+// collects it for whoever last hit the ball. The zero PowerUps has none.
+type PowerUps struct {
+	// Kinds are the power-ups that can appear.
+	Kinds [NumPowers]bool
+	// Interval is the seconds between power-ups appearing.
+	Interval float64
+	// Duration is how many seconds an effect lasts.
+	Duration float64
+}
+
+// PowerUp is one waiting to be collected. Only Live ones are in the arena.
+type PowerUp struct {
+	Live  bool
+	Kind  PowerKind
+	Coord Pair
+}
+
+// Effect is a power-up working on a player. It lasts while Ticks are left.
+type Effect struct {
+	Ticks  int
+	Stacks int
+}
+
+// Active reports whether side has the kind of effect on them.
+func (s *State) Active(side Side, kind PowerKind) bool {
+	return s.Effects[side][kind].Ticks > 0
+}
+
+// Hidden reports whether b is invisible to the players.
+func (s *State) Hidden(b *Ball) bool {
+	return b.Hits > 0 && s.Active(b.LastHit, PowerInvisible)
+}
+
+// slowed reports whether anyone has slow motion in effect.
+func (s *State) slowed() bool {
+	for side := range s.Effects {
+		if s.Active(Side(side), PowerSlow) {
+			return true
+		}
+	}
+	return false
+}
+
+// tickPowerUps runs the effects down and, every PowerUps.Interval, puts a
+// new power-up in the arena.
+func (s *State) tickPowerUps(events []Event) []Event {
+	for side := range s.Effects {
+		for kind := range s.Effects[side] {
+			e := &s.Effects[side][kind]
+			if e.Ticks > 0 {
+				if e.Ticks--; e.Ticks == 0 {
+					e.Stacks = 0
+				}
+			}
+		}
+	}
+	s.resizePaddles()
+
+	if s.Config.PowerUps.Interval <= 0 {
+		return events
+	}
+	if s.PowerUpIn--; s.PowerUpIn > 0 {
+		return events
+	}
+	s.PowerUpIn = int(s.Config.PowerUps.Interval * TickRate)
+	return s.placePowerUp(events)
+}
+
+// placePowerUp puts a random kind of power-up somewhere in the middle of the
+// arena clear of obstacles, if there is room for another.
+func (s *State) placePowerUp(events []Event) []Event {
+	var kinds []PowerKind
+	for k, ok := range s.Config.PowerUps.Kinds {
+		if ok {
+			kinds = append(kinds, PowerKind(k))
+		}
+	}
+	if len(kinds) == 0 {
+		return events
+	}
+	for i := range s.PowerUps {
+		p := &s.PowerUps[i]
+		if p.Live {
+			continue
+		}
+		p.Kind = kinds[s.Rand.Uint64()%uint64(len(kinds))]
+		// Try a few spots in case obstacles are in the way.
+		for tries := 0; tries < 8; tries++ {
+			p.Coord.X = ArenaWidth * (0.25 + 0.5*s.Rand.Float64())
+			p.Coord.Y = ArenaHeight * (0.25 + 0.5*s.Rand.Float64())
+			if s.clear(p.Coord, PowerUpRadius) {
+				p.Live = true
+				return append(events, Event{Kind: EventPowerUp, Power: p.Kind})
+			}
+		}
+		return events
+	}
+	return events
+}
+
+// collectPowerUps hands any power-up ball i runs into to whoever last hit
+// it. Balls nobody has hit pass straight over them.
+func (s *State) collectPowerUps(i int, events []Event) []Event {
+	b := &s.Balls[i]
+	if !b.Live || b.Hits == 0 {
+		return events
+	}
+	for j := range s.PowerUps {
+		p := &s.PowerUps[j]
+		if !p.Live || math.Hypot(p.Coord.X-b.Coord.X, p.Coord.Y-b.Coord.Y) >= PowerUpRadius+b.Radius {
+			continue
+		}
+		p.Live = false
+		events = append(events, Event{Kind: EventCollect, Side: b.LastHit, Ball: i, Power: p.Kind})
+		switch p.Kind {
+		case PowerSplit:
+			events = s.split(i, events)
+		case PowerShrink:
+			for side := Side(0); side < MaxSides; side++ {
+				if side != b.LastHit && s.InPlay(side) {
+					s.affect(side, p.Kind)
+				}
+			}
+		default:
+			s.affect(b.LastHit, p.Kind)
+		}
+	}
+	return events
+}
+
+// affect starts, or adds to, kind's effect on side.
+func (s *State) affect(side Side, kind PowerKind) {
+	e := &s.Effects[side][kind]
+	e.Ticks = int(s.Config.PowerUps.Duration * TickRate)
+	if e.Stacks == 0 || kind.Stacks() && e.Stacks < MaxStacks {
+		e.Stacks++
+	}
+	s.resizePaddles()
+}
+
+// split turns ball i into two heading either side of where it was going.
+// Nothing happens if every ball is already in play.
+func (s *State) split(i int, events []Event) []Event {
+	for j := range s.Balls {
+		if s.Balls[j].Live {
+			continue
+		}
+		s.Balls[j] = s.Balls[i]
+		s.Balls[i].Velocity = rotate(s.Balls[i].Velocity, splitAngle/2)
+		s.Balls[j].Velocity = rotate(s.Balls[j].Velocity, -splitAngle/2)
+		s.keepCrossing(&s.Balls[i])
+		s.keepCrossing(&s.Balls[j])
+		return append(events, Event{Kind: EventSpawn, Side: s.Balls[j].LastHit, Ball: j})
+	}
+	return events
+}
+
+func rotate(p Pair, angle float64) Pair {
+	sin, cos := math.Sincos(angle)
+	return Pair{X: p.X*cos - p.Y*sin, Y: p.X*sin + p.Y*cos}
+}
+
+// resizePaddles sets each paddle's length from its grow and shrink
+// effects, keeping it centred where it was and inside the arena.
+func (s *State) resizePaddles() {
+	for side := Side(0); side < MaxSides; side++ {
+		grow, shrink := s.Effects[side][PowerGrow].Stacks, s.Effects[side][PowerShrink].Stacks
+		length := s.Config.PaddleHeight * (1 + growStep*float64(grow)) * (1 - shrinkStep*float64(shrink))
+		paddle := s.Paddle(side)
+		pos, size, limit, center := &paddle.Y, &paddle.H, ArenaHeight, paddle.Center().Y
+		if side.Horizontal() {
+			pos, size, limit, center = &paddle.X, &paddle.W, ArenaWidth, paddle.Center().X
+		}
+		if *size == length {
+			continue
+		}
+		*size = length
+		*pos = math.Max(0, math.Min(limit-length, center-length/2))
+	}
+}
+
+// speedUp makes a ball just hit by side faster if they have a speed boost,
+// though never faster than its top speed, past which sub-stepping could
+// let it through a paddle.
+func (s *State) speedUp(b *Ball, side Side) {
+	if !s.Active(side, PowerSpeed) {
+		return
+	}
+	boost := 1 + speedStep*float64(s.Effects[side][PowerSpeed].Stacks)
+	speed := math.Hypot(b.Velocity.X, b.Velocity.Y)
+	if max := b.VelocityBounds.Y; speed*boost > max && speed > 0 {
+		boost = max / speed
+	}
+	b.Velocity.X *= boost
+	b.Velocity.Y *= boost
+}
+
+// stick catches b on side's paddle if it is sticky.
+func (s *State) stick(b *Ball, side Side) {
+	if !s.Active(side, PowerSticky) {
+		return
+	}
+	b.Stuck = int(StickyHold * TickRate)
+	b.StuckTo = side
+	center := s.Paddle(side).Center()
+	b.StuckAt = Pair{X: b.Coord.X - center.X, Y: b.Coord.Y - center.Y}
+}
+
+// followPaddles keeps stuck balls where they were caught on their
+// paddles.
+func (s *State) followPaddles() {
+	for i := range s.Balls {
+		b := &s.Balls[i]
+		if b.Live && b.Stuck > 0 {
+			center := s.Paddle(b.StuckTo).Center()
+			b.Coord = Pair{X: center.X + b.StuckAt.X, Y: center.Y + b.StuckAt.Y}
+		}
+	}
+}
+
+// releaseStuck lets stuck balls go when their time is up or the player
+// holding them presses start. A released ball comes off the paddle as if it
+// had just hit it where it is now, so moving the paddle aims it.
+func (s *State) releaseStuck(pressedBy [MaxSides]Buttons, events []Event) []Event {
+	for i := range s.Balls {
+		b := &s.Balls[i]
+		if !b.Live || b.Stuck == 0 {
+			continue
+		}
+		if b.Stuck--; b.Stuck == 0 || pressedBy[b.StuckTo]&ButtonStart != 0 {
+			b.Stuck = 0
+			s.bouncePaddle(b, b.StuckTo, b.StuckTo.Inward())
+			s.speedUp(b, b.StuckTo)
+			events = append(events, Event{Kind: EventRelease, Side: b.StuckTo, Ball: i})
+		}
+	}
+	return events
+}
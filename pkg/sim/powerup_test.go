@@ -0,0 +1,30 @@
+package sim
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSpeedUpClamped(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		stacks int
+		speed  float64
+		want   float64
+	}{
+		{"one stack", 1, 2, 2.5},
+		{"stacked past the top speed", MaxStacks, 6, DefaultConfig().MaxBallSpeed},
+		{"already at the top speed", 1, DefaultConfig().MaxBallSpeed, DefaultConfig().MaxBallSpeed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewState(DefaultConfig(), 1)
+			s.Effects[Left][PowerSpeed] = Effect{Ticks: TickRate, Stacks: tc.stacks}
+			b := &s.Balls[0]
+			b.Velocity = Pair{X: tc.speed * 0.6, Y: tc.speed * 0.8}
+			s.speedUp(b, Left)
+			if got := math.Hypot(b.Velocity.X, b.Velocity.Y); math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("speed %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package sim
+
+import (
+	"math"
+)
+
+// Intercept is where and when a ball will cross a given vertical line.
+type Intercept struct {
+	Y       float64
+	Time    float64
+	Bounces int
+}
+
+// PredictPosition returns where the ball's centre will be after t seconds
+// if nothing but the top and bottom walls of an arena of the given height
+// gets in its way, and how many times it will have bounced. Bounces work as
+// in Step: the ball is reflected when its edge touches a wall.
+func PredictPosition(b Ball, t, height float64) (Pair, int) {
+	x := b.Coord.X + b.Velocity.X*t
+
+	// Between the walls the centre moves freely in a band of height span.
+	// Unfolding the band, mirroring it at every wall, turns the bouncing
+	// path into a straight line.
+	lo, span := b.Radius, height-2*b.Radius
+	if span <= 0 {
+		return Pair{X: x, Y: height / 2}, 0
+	}
+	unfolded := b.Coord.Y + b.Velocity.Y*t - lo
+	period := math.Floor(unfolded / span)
+	y := unfolded - period*span
+	if int64(period)%2 != 0 {
+		y = span - y
+	}
+	return Pair{X: x, Y: lo + y}, int(math.Abs(period))
+}
+
+// PredictIntercept works out when and where the ball's centre will reach
+// the vertical line at x, bouncing off the top and bottom of an arena of
+// the given height. It reports false if the ball is not moving towards x.
+func PredictIntercept(b Ball, x, height float64) (Intercept, bool) {
+	if b.Velocity.X == 0 {
+		return Intercept{}, false
+	}
+	t := (x - b.Coord.X) / b.Velocity.X
+	if t < 0 {
+		return Intercept{}, false
+	}
+	p, bounces := PredictPosition(b, t, height)
+	return Intercept{Y: p.Y, Time: t, Bounces: bounces}, true
+}
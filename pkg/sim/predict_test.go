@@ -0,0 +1,66 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestPredictIntercept runs balls through Step with idle paddles and checks
+// PredictIntercept gets the tick, height and bounces of the moment each
+// crosses a line short of the far paddle.
+func TestPredictIntercept(t *testing.T) {
+	config := DefaultConfig()
+	for seed := int64(1); seed <= 50; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		for _, degrees := range []float64{-70, -60, -45, -30, -10, 0, 5, 25, 40, 55, 70} {
+			s := NewState(config, seed)
+			s.Mode = ModePlay
+			b := &s.Balls[0]
+			angle := degrees * math.Pi / 180
+			speed := config.BallSpeed + r.Float64()*(config.MaxBallSpeed-config.BallSpeed)
+			b.Coord = Pair{X: 3.5, Y: b.Radius + r.Float64()*(ArenaHeight-2*b.Radius)}
+			b.Velocity = Pair{X: -speed * math.Cos(angle), Y: speed * math.Sin(angle)}
+			start := *b
+			const x = 0.5
+
+			want, ok := PredictIntercept(start, x, ArenaHeight)
+			if !ok {
+				t.Fatalf("seed %d, %v degrees: no intercept", seed, degrees)
+			}
+			tick, bounces := 0, 0
+			for s.Balls[0].Coord.X > x {
+				var events []Event
+				s, events = Step(s, Inputs{})
+				tick++
+				for _, e := range events {
+					switch e.Kind {
+					case EventWallBounce:
+						bounces++
+					case EventPaddleHit, EventGoal:
+						t.Fatalf("seed %d, %v degrees: ball never reached x = %v", seed, degrees, x)
+					}
+				}
+			}
+
+			if wantTick := int(math.Ceil(want.Time/Dt - 1e-9)); tick != wantTick {
+				t.Errorf("seed %d, %v degrees: crossed on tick %d, predicted %d", seed, degrees, tick, wantTick)
+			}
+			got := s.Balls[0].Coord
+			p, predicted := PredictPosition(start, float64(tick)*Dt, ArenaHeight)
+			if math.Abs(p.X-got.X) > 1e-9 || math.Abs(p.Y-got.Y) > 1e-9 {
+				t.Errorf("seed %d, %v degrees: ball at %+v on tick %d, predicted %+v", seed, degrees, got, tick, p)
+			}
+			// The bounces Step made by the end of the tick, which may
+			// include one just after the crossing.
+			if bounces != predicted || predicted-want.Bounces > 1 {
+				t.Errorf("seed %d, %v degrees: %d bounces, predicted %d by the tick and %d by the crossing", seed, degrees, bounces, predicted, want.Bounces)
+			}
+			// The crossing is between ticks, so the ball is within a tick's
+			// travel of the predicted height.
+			if math.Abs(want.Y-got.Y) > speed*Dt {
+				t.Errorf("seed %d, %v degrees: crossed at %v, predicted %v", seed, degrees, got.Y, want.Y)
+			}
+		}
+	}
+}
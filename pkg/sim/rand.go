@@ -0,0 +1,24 @@
+package sim
+
+// Rand is a small random number generator kept in State, so copies of a
+// state, replays and rollbacks all draw the same numbers. It is SplitMix64.
+type Rand struct {
+	Seed uint64
+}
+
+func NewRand(seed int64) Rand {
+	return Rand{Seed: uint64(seed)}
+}
+
+func (r *Rand) Uint64() uint64 {
+	r.Seed += 0x9e3779b97f4a7c15
+	z := r.Seed
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return z ^ z>>31
+}
+
+// Float64 returns a number in [0, 1).
+func (r *Rand) Float64() float64 {
+	return float64(r.Uint64()>>11) / (1 << 53)
+}
@@ -0,0 +1,962 @@
+package sim
+
+import (
+	"math"
+)
+
+// The simulation runs in a fixed logical arena of ArenaWidth by ArenaHeight
+// units with the origin in the top left corner. Renderers scale it to
+// whatever window they have; the window size never reaches the simulation.
+const (
+	ArenaWidth  = 4.0
+	ArenaHeight = 3.0
+)
+
+type Mode int
+
+const (
+	ModeWait Mode = iota
+	ModePlay
+	ModePause
+	// ModeOver is the end of a match; pressing start begins a rematch.
+	ModeOver
+	// ModeServe holds the ball until the countdown runs out or, for a
+	// manual serve, the server presses start. Paddles can move meanwhile.
+	ModeServe
+)
+
+type Side int
+
+const (
+	Left Side = iota
+	Right
+	// Top and Bottom only have paddles in four player matches; otherwise
+	// they are walls.
+	Top
+	Bottom
+)
+
+// MaxSides is the most paddles a match can have.
+const MaxSides = 4
+
+// Horizontal reports whether the side's paddle lies along the top or bottom
+// of the arena, and so moves sideways.
+func (side Side) Horizontal() bool {
+	return side == Top || side == Bottom
+}
+
+// Inward is the unit vector from the side's wall into the arena.
+func (side Side) Inward() Pair {
+	switch side {
+	case Right:
+		return Pair{X: -1}
+	case Top:
+		return Pair{Y: 1}
+	case Bottom:
+		return Pair{Y: -1}
+	}
+	return Pair{X: 1}
+}
+
+type Pair struct {
+	X float64
+	Y float64
+}
+
+// Transposed swaps X and Y, which turns a horizontal paddle's view of the
+// arena into a vertical one's.
+func (p Pair) Transposed() Pair {
+	return Pair{X: p.Y, Y: p.X}
+}
+
+func (p Pair) Dot(q Pair) float64 {
+	return p.X*q.X + p.Y*q.Y
+}
+
+type Buttons uint8
+
+const (
+	ButtonStart Buttons = 1 << iota
+	ButtonPause
+)
+
+// Intent is what a player wants their paddle to do for one tick. Axis runs
+// from -1 (full speed up) to 1 (full speed down). If Track is set the paddle
+// instead heads for Target, the height its centre should be at, no faster
+// than Config.TrackSpeed. Horizontal paddles read up as left and heights as
+// distances from the left. Buttons are the ones held down; the simulation
+// works out presses itself so callers need not track edges.
+type Intent struct {
+	Axis    float64
+	Track   bool
+	Target  float64
+	Buttons Buttons
+}
+
+// Inputs holds one Intent per side, indexed by Side.
+type Inputs [MaxSides]Intent
+
+type EventKind int
+
+const (
+	EventStart EventKind = iota
+	EventPause
+	EventResume
+	EventPaddleHit
+	EventWallBounce
+	EventScore
+	EventSet
+	EventMatch
+	// EventServe is the ball being launched; Side is the server.
+	EventServe
+	// EventGoal is the ball going into Side's goal. It comes before the
+	// EventScore for whoever gets the point, if anyone does.
+	EventGoal
+	// EventOut is Side losing their last life.
+	EventOut
+	// EventSpawn is another ball coming into play, heading for Side.
+	EventSpawn
+	// EventBallHit is two balls bouncing off each other. Side means
+	// nothing.
+	EventBallHit
+	// EventPowerUp is a power-up appearing in the arena.
+	EventPowerUp
+	// EventCollect is Side collecting a power-up with a ball.
+	EventCollect
+	// EventRelease is Side letting go of a ball stuck to their paddle.
+	EventRelease
+	// EventObstacleHit is a ball bouncing off an obstacle. Side means
+	// nothing.
+	EventObstacleHit
+)
+
+// Event reports something that happened during a Step so renderers can play
+// sounds or update effects without inspecting state diffs. For EventScore,
+// EventSet and EventMatch, Side is the player who won the point, set or
+// match. Ball is the index in State.Balls of the ball a paddle hit, wall
+// bounce, obstacle hit, goal, spawn, collection or release happened to,
+// and Power is the kind of power-up that appeared or was collected.
+type Event struct {
+	Kind  EventKind
+	Side  Side
+	Ball  int
+	Power PowerKind
+}
+
+// Ball is a circle centred on Coord. Only Live balls are in play.
+type Ball struct {
+	Live           bool
+	Radius         float64
+	Coord          Pair
+	Velocity       Pair
+	VelocityBounds Pair
+	BaseSpeed      float64
+	// Hits counts the paddles the ball has come off, and LastHit is the
+	// last of them if there were any.
+	Hits    int
+	LastHit Side
+	// Stuck is the ticks left until a ball caught by a sticky paddle is
+	// let go. It is held on StuckTo's paddle at StuckAt from the centre.
+	Stuck   int
+	StuckTo Side
+	StuckAt Pair
+}
+
+// MaxBalls is the most balls that can be in play at once.
+const MaxBalls = 8
+
+type Paddle struct {
+	Rect
+}
+
+// Config holds the tunables of a match. Lengths are in arena units and speeds
+// in arena units per second.
+type Config struct {
+	BallSpeed      float64
+	MaxBallSpeed   float64
+	PaddleSpeed    float64
+	BallRadius     float64
+	PaddleWidth    float64
+	PaddleHeight   float64
+	PaddleDistance float64
+	// TrackSpeed caps how fast a paddle following a pointer can move, so
+	// absolute control is not much stronger than holding a key.
+	TrackSpeed float64
+	// Players is 2, or 4 for paddles on every wall. Zero means 2.
+	Players   int
+	Rules     Rules
+	Serve     Serve
+	MultiBall MultiBall
+	PowerUps  PowerUps
+	Arena     Arena
+}
+
+// Sides is how many paddles the match has.
+func (c Config) Sides() int {
+	if c.Players == MaxSides {
+		return MaxSides
+	}
+	return 2
+}
+
+type ServeRule int
+
+const (
+	// ServeAlternate swaps the server after every point.
+	ServeAlternate ServeRule = iota
+	// ServeLoser has whoever lost the point serve the next.
+	ServeLoser
+	// ServeWinner has whoever won the point serve the next.
+	ServeWinner
+)
+
+// MaxServeCone is the widest Serve.Cone allowed. Steeper serves would take
+// longer to cross the arena than to be worth returning.
+const MaxServeCone = math.Pi / 3
+
+// Serve decides who puts the ball in play and how.
+type Serve struct {
+	Rule ServeRule
+	// Cone is the largest angle from the horizontal, in radians, the ball is
+	// served at. Every serve picks an angle within it at random.
+	Cone float64
+	// Countdown is how many seconds the ball is held before it is served.
+	Countdown float64
+	// Manual holds the ball on the server's paddle until they press start.
+	Manual bool
+}
+
+// MultiBall puts extra balls in play during a round. The zero MultiBall
+// plays with one ball.
+type MultiBall struct {
+	// Balls is the most balls in play at once, up to MaxBalls.
+	Balls int
+	// Interval is the seconds between extra balls, if set.
+	Interval float64
+	// EveryHits adds a ball every this many paddle hits, if set.
+	EveryHits int
+	// FirstGoal ends the round at the first goal rather than when the last
+	// ball has gone.
+	FirstGoal bool
+}
+
+// Rules decide when a match is over. Zero values mean no limit, so the zero
+// Rules is an endless match.
+type Rules struct {
+	// Points is how many points win a set.
+	Points int
+	// WinBy is the lead needed to win a set, as in win by two.
+	WinBy int
+	// Sets is how many sets the match is the best of.
+	Sets int
+	// Lives is how many goals a player can let in before they are out, if
+	// set. The last player left wins, and points and sets don't count.
+	Lives int
+}
+
+// SetsToWin is how many sets a player needs to win the match.
+func (r Rules) SetsToWin() int {
+	return r.Sets/2 + 1
+}
+
+// wonSet reports whether score wins a set against other.
+func (r Rules) wonSet(score, other int) bool {
+	winBy := r.WinBy
+	if winBy < 1 {
+		winBy = 1
+	}
+	return r.Points > 0 && score >= r.Points && score-other >= winBy
+}
+
+// Stats are kept over a whole match for the game over screen.
+type Stats struct {
+	// Ticks is the time spent in play.
+	Ticks        int
+	Points       [MaxSides]int
+	Hits         [MaxSides]int
+	LongestRally int
+	// Rally counts the hits since the last point.
+	Rally int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		BallSpeed:      ArenaWidth * 0.6,
+		MaxBallSpeed:   ArenaWidth * 1.8,
+		PaddleSpeed:    ArenaHeight,
+		BallRadius:     ArenaWidth / 120,
+		PaddleWidth:    ArenaWidth / 60,
+		PaddleHeight:   ArenaHeight / 5,
+		PaddleDistance: ArenaWidth / 16,
+		TrackSpeed:     ArenaHeight * 1.5,
+		Rules:          Rules{Points: 11, WinBy: 2, Sets: 1},
+		Serve:          Serve{Rule: ServeAlternate, Cone: math.Pi / 4, Countdown: 1},
+	}
+}
+
+type State struct {
+	Config Config
+	Mode   Mode
+	// Score is the points in the current set and Sets the sets won.
+	Score [MaxSides]int
+	Sets  [MaxSides]int
+	// Lives are left over from Rules.Lives.
+	Lives [MaxSides]int
+	// Winner is who won the match once Mode is ModeOver.
+	Winner Side
+	Stats  Stats
+	// Server serves the next ball, and Countdown is the ticks left before
+	// they do.
+	Server    Side
+	Countdown int
+	// SpawnIn is the ticks left until MultiBall.Interval adds a ball, and
+	// PowerUpIn until PowerUps.Interval adds a power-up.
+	SpawnIn      int
+	PowerUpIn    int
+	Rand         Rand
+	Held         [MaxSides]Buttons
+	LeftPaddle   Paddle
+	RightPaddle  Paddle
+	TopPaddle    Paddle
+	BottomPaddle Paddle
+	// Balls[0] is the one served; the rest are spawned by MultiBall or
+	// split off by power-ups.
+	Balls    [MaxBalls]Ball
+	PowerUps [MaxPowerUps]PowerUp
+	// Effects are the power-ups working on each player.
+	Effects [MaxSides][NumPowers]Effect
+}
+
+// NewState starts a match. The seed decides the serve angles.
+func NewState(config Config, seed int64) State {
+	s := State{Config: config, Mode: ModeWait, Rand: NewRand(seed)}
+	s.newMatch()
+	s.Reset()
+	return s
+}
+
+// newMatch clears the scores, lives, stats and power-ups of the last match.
+func (s *State) newMatch() {
+	s.Score, s.Sets, s.Lives = [MaxSides]int{}, [MaxSides]int{}, [MaxSides]int{}
+	s.Stats = Stats{}
+	s.PowerUps = [MaxPowerUps]PowerUp{}
+	s.Effects = [MaxSides][NumPowers]Effect{}
+	s.PowerUpIn = int(s.Config.PowerUps.Interval * TickRate)
+	for side := 0; side < s.Config.Sides(); side++ {
+		s.Lives[side] = s.Config.Rules.Lives
+	}
+}
+
+// InPlay reports whether side has a paddle defending its goal. Every other
+// wall, including those of players who are out, is solid.
+func (s *State) InPlay(side Side) bool {
+	if int(side) >= s.Config.Sides() {
+		return false
+	}
+	return s.Config.Rules.Lives == 0 || s.Lives[side] > 0
+}
+
+func (s *State) Reset() {
+	s.Balls = [MaxBalls]Ball{}
+	s.Balls[0] = s.newBall()
+
+	s.LeftPaddle = Paddle{Rect: Rect{
+		X: s.Config.PaddleDistance,
+		Y: (ArenaHeight - s.Config.PaddleHeight) / 2,
+		W: s.Config.PaddleWidth,
+		H: s.Config.PaddleHeight,
+	}}
+	s.RightPaddle = s.LeftPaddle
+	s.RightPaddle.X = ArenaWidth - s.Config.PaddleDistance - s.Config.PaddleWidth
+
+	s.TopPaddle = Paddle{Rect: Rect{
+		X: (ArenaWidth - s.Config.PaddleHeight) / 2,
+		Y: s.Config.PaddleDistance,
+		W: s.Config.PaddleHeight,
+		H: s.Config.PaddleWidth,
+	}}
+	s.BottomPaddle = s.TopPaddle
+	s.BottomPaddle.Y = ArenaHeight - s.Config.PaddleDistance - s.Config.PaddleWidth
+	s.resizePaddles()
+}
+
+// newBall is a ball at rest where balls come into play.
+func (s *State) newBall() Ball {
+	b := Ball{Live: true}
+	b.Coord = s.spawnPoint()
+	b.Radius = s.Config.BallRadius
+	b.BaseSpeed = s.Config.BallSpeed
+
+	b.VelocityBounds.X = b.BaseSpeed
+	b.VelocityBounds.Y = s.Config.MaxBallSpeed
+	return b
+}
+
+// Step advances the simulation by one tick of Dt seconds. It never touches
+// the renderer or audio; anything the caller may want to react to is
+// returned as events.
+func Step(s State, in Inputs) (State, []Event) {
+	var events []Event
+
+	var pressedBy [MaxSides]Buttons
+	var pressed Buttons
+	for i, intent := range in {
+		pressedBy[i] = intent.Buttons &^ s.Held[i]
+		pressed |= pressedBy[i]
+		s.Held[i] = intent.Buttons
+	}
+	start := pressed&ButtonStart != 0
+	pause := pressed&ButtonPause != 0
+
+	switch s.Mode {
+	case ModeOver:
+		if start {
+			s.newMatch()
+			s.Server = s.nextInPlay(s.Winner)
+			s.Reset()
+			s.startServe()
+			events = append(events, Event{Kind: EventStart})
+		}
+		return s, events
+	case ModeServe:
+		s.movePaddles(in, Dt)
+		if s.Config.Serve.Manual {
+			s.holdBall()
+			if pressedBy[s.Server]&ButtonStart != 0 {
+				events = s.serve(events)
+			}
+			return s, events
+		}
+		if s.Countdown--; s.Countdown <= 0 {
+			events = s.serve(events)
+		}
+		return s, events
+	case ModePause:
+		if start {
+			s.Mode = ModePlay
+			events = append(events, Event{Kind: EventResume})
+		}
+		return s, events
+	case ModeWait:
+		if start {
+			s.Reset()
+			s.startServe()
+			events = append(events, Event{Kind: EventStart})
+		}
+		return s, events
+	}
+
+	if pause {
+		s.Mode = ModePause
+		events = append(events, Event{Kind: EventPause})
+		return s, events
+	}
+
+	s.Stats.Ticks++
+	if s.Config.MultiBall.Interval > 0 {
+		if s.SpawnIn--; s.SpawnIn <= 0 {
+			s.SpawnIn = int(s.Config.MultiBall.Interval * TickRate)
+			events = s.spawn(events)
+		}
+	}
+	events = s.tickPowerUps(events)
+	events = s.releaseStuck(pressedBy, events)
+	s.pushOut()
+
+	// Fast balls are moved in several sub-steps so a paddle moving into the
+	// ball's path during the tick is seen where it is at that moment, not
+	// only where it ends up. The fastest ball sets the pace for them all.
+	substeps := 1
+	for i := range s.Balls {
+		b := &s.Balls[i]
+		if !b.Live {
+			continue
+		}
+		speed := math.Hypot(b.Velocity.X, b.Velocity.Y)
+		if n := int(math.Ceil(speed * Dt / b.Radius)); n > substeps {
+			substeps = n
+		}
+	}
+	if substeps > maxSubsteps {
+		substeps = maxSubsteps
+	}
+	dt := Dt / float64(substeps)
+	ballDt := dt
+	if s.slowed() {
+		ballDt *= slowFactor
+	}
+	for i := 0; i < substeps && s.Mode == ModePlay; i++ {
+		s.movePaddles(in, dt)
+		s.followPaddles()
+		for b := range s.Balls {
+			if s.Balls[b].Live && s.Balls[b].Stuck == 0 {
+				events = s.moveBall(b, ballDt, events)
+				events = s.collectPowerUps(b, events)
+			}
+		}
+		events = s.collideBalls(events)
+		events = s.checkScore(events)
+	}
+	return s, events
+}
+
+const (
+	maxSubsteps = 16
+	// maxBounces bounds the contacts resolved in one sub-step, which only
+	// matters if the ball gets wedged between a paddle and a wall.
+	maxBounces = 4
+)
+
+// startServe holds the ball for the next serve.
+func (s *State) startServe() {
+	s.Mode = ModeServe
+	s.Countdown = int(s.Config.Serve.Countdown * TickRate)
+	if s.Config.Serve.Manual {
+		s.holdBall()
+	}
+}
+
+// holdBall puts the served ball against the middle of the server's paddle.
+func (s *State) holdBall() {
+	ball := &s.Balls[0]
+	paddle := s.Paddle(s.Server)
+	inward := s.Server.Inward()
+	offset := paddle.W/2 + ball.Radius
+	if s.Server.Horizontal() {
+		offset = paddle.H/2 + ball.Radius
+	}
+	center := paddle.Center()
+	ball.Coord = Pair{X: center.X + inward.X*offset, Y: center.Y + inward.Y*offset}
+}
+
+// serve launches the ball away from the server.
+func (s *State) serve(events []Event) []Event {
+	s.launch(&s.Balls[0], s.Server.Inward())
+	s.SpawnIn = int(s.Config.MultiBall.Interval * TickRate)
+	s.Mode = ModePlay
+	return append(events, Event{Kind: EventServe, Side: s.Server})
+}
+
+// launch sends b in the direction away at a random angle within the serve
+// cone, always crossing the arena at the base speed.
+func (s *State) launch(b *Ball, away Pair) {
+	cone := math.Min(s.Config.Serve.Cone, MaxServeCone)
+	angle := (s.Rand.Float64()*2 - 1) * cone
+	across := math.Tan(angle)
+	b.Velocity.X = b.BaseSpeed * (away.X + across*math.Abs(away.Y))
+	b.Velocity.Y = b.BaseSpeed * (away.Y + across*math.Abs(away.X))
+}
+
+// spawn puts another ball in play from the middle of the arena, heading
+// for a random player, unless MultiBall.Balls are in play already.
+func (s *State) spawn(events []Event) []Event {
+	limit := s.Config.MultiBall.Balls
+	if limit > MaxBalls {
+		limit = MaxBalls
+	}
+	free, live := -1, 0
+	for i := range s.Balls {
+		if s.Balls[i].Live {
+			live++
+		} else if free < 0 {
+			free = i
+		}
+	}
+	if live >= limit || free < 0 {
+		return events
+	}
+
+	var sides []Side
+	for side := Side(0); side < MaxSides; side++ {
+		if s.InPlay(side) {
+			sides = append(sides, side)
+		}
+	}
+	target := sides[s.Rand.Uint64()%uint64(len(sides))]
+	inward := target.Inward()
+	b := &s.Balls[free]
+	*b = s.newBall()
+	s.launch(b, Pair{X: -inward.X, Y: -inward.Y})
+	return append(events, Event{Kind: EventSpawn, Side: target, Ball: free})
+}
+
+func (s *State) movePaddles(in Inputs, dt float64) {
+	for side := Side(0); side < MaxSides; side++ {
+		if s.InPlay(side) {
+			s.movePaddle(side, in[side], dt)
+		}
+	}
+}
+
+func (s *State) movePaddle(side Side, in Intent, dt float64) {
+	paddle := s.Paddle(side)
+	pos, length, limit, center := &paddle.Y, paddle.H, ArenaHeight, paddle.Center().Y
+	if side.Horizontal() {
+		pos, length, limit, center = &paddle.X, paddle.W, ArenaWidth, paddle.Center().X
+	}
+	if in.Track {
+		max := s.Config.TrackSpeed * dt
+		*pos += math.Max(-max, math.Min(max, in.Target-center))
+	} else {
+		axis := math.Max(-1, math.Min(1, in.Axis))
+		*pos += axis * s.Config.PaddleSpeed * dt
+	}
+	*pos = math.Max(0, math.Min(limit-length, *pos))
+}
+
+// MovePaddle moves the paddle on side as Step would over dt seconds. Network
+// clients use it to predict their own paddle ahead of the server.
+func (s *State) MovePaddle(side Side, in Intent, dt float64) {
+	s.movePaddle(side, in, dt)
+}
+
+// Paddle returns the paddle on the given side.
+func (s *State) Paddle(side Side) *Paddle {
+	switch side {
+	case Right:
+		return &s.RightPaddle
+	case Top:
+		return &s.TopPaddle
+	case Bottom:
+		return &s.BottomPaddle
+	}
+	return &s.LeftPaddle
+}
+
+// WallDistance is how far p is from side's wall.
+func WallDistance(side Side, p Pair) float64 {
+	switch side {
+	case Right:
+		return ArenaWidth - p.X
+	case Top:
+		return p.Y
+	case Bottom:
+		return ArenaHeight - p.Y
+	}
+	return p.X
+}
+
+func (s *State) checkScore(events []Event) []Event {
+	for i := range s.Balls {
+		b := &s.Balls[i]
+		for side := Side(0); side < MaxSides && b.Live && s.Mode == ModePlay; side++ {
+			if !s.InPlay(side) {
+				continue
+			}
+			if WallDistance(side, b.Coord) < b.Radius && b.Velocity.Dot(side.Inward()) < 0 {
+				events = s.goal(i, side, events)
+			}
+		}
+	}
+	return events
+}
+
+// goal lets ball i into side's goal. In a two player match the other side
+// gets the point; with four, whoever hit the ball last does, unless it was
+// an own goal. Then it works out whether that wins a set or the match, and
+// whether the round is over.
+func (s *State) goal(i int, side Side, events []Event) []Event {
+	b := &s.Balls[i]
+	b.Live = false
+	events = append(events, Event{Kind: EventGoal, Side: side, Ball: i})
+	scorer, scored := b.LastHit, b.Hits > 0 && b.LastHit != side
+	if s.Config.Sides() == 2 {
+		scorer, scored = 1-side, true
+	}
+	if s.Stats.Rally > s.Stats.LongestRally {
+		s.Stats.LongestRally = s.Stats.Rally
+	}
+	s.Stats.Rally = 0
+	if scored {
+		s.Score[scorer]++
+		s.Stats.Points[scorer]++
+		events = append(events, Event{Kind: EventScore, Side: scorer})
+	}
+
+	rules := s.Config.Rules
+	if rules.Lives > 0 {
+		if s.Lives[side]--; s.Lives[side] == 0 {
+			events = append(events, Event{Kind: EventOut, Side: side})
+		}
+		if left := s.nextInPlay(side); s.nextInPlay(left) == left {
+			return s.endMatch(left, events)
+		}
+	} else if scored && rules.wonSet(s.Score[scorer], s.bestOther(scorer)) {
+		s.Sets[scorer]++
+		events = append(events, Event{Kind: EventSet, Side: scorer})
+		if s.Sets[scorer] >= rules.SetsToWin() {
+			return s.endMatch(scorer, events)
+		}
+		s.Score = [MaxSides]int{}
+		return s.endRound(side, scorer, scored, events)
+	}
+
+	if s.Config.MultiBall.FirstGoal || s.liveBalls() == 0 {
+		return s.endRound(side, scorer, scored, events)
+	}
+	return events
+}
+
+func (s *State) liveBalls() int {
+	live := 0
+	for i := range s.Balls {
+		if s.Balls[i].Live {
+			live++
+		}
+	}
+	return live
+}
+
+// endRound stops play after the last goal of a round, which went into
+// side's goal, and picks the next server.
+func (s *State) endRound(side, scorer Side, scored bool, events []Event) []Event {
+	s.Mode = ModeWait
+	switch {
+	case s.Config.Serve.Rule == ServeAlternate:
+		s.Server = s.nextInPlay(s.Server)
+	case s.Config.Serve.Rule == ServeWinner && scored:
+		s.Server = scorer
+	case s.InPlay(side):
+		s.Server = side
+	default:
+		s.Server = s.nextInPlay(side)
+	}
+
+	// A manual server gets the ball straight away; there is nothing to
+	// wait for.
+	if s.Config.Serve.Manual {
+		s.Reset()
+		s.startServe()
+	}
+	return events
+}
+
+func (s *State) endMatch(winner Side, events []Event) []Event {
+	s.Mode = ModeOver
+	s.Winner = winner
+	return append(events, Event{Kind: EventMatch, Side: winner})
+}
+
+// bestOther is the highest score of anyone but side.
+func (s *State) bestOther(side Side) int {
+	best := 0
+	for other := 0; other < s.Config.Sides(); other++ {
+		if Side(other) != side && s.Score[other] > best {
+			best = s.Score[other]
+		}
+	}
+	return best
+}
+
+// nextInPlay is the first side after side, going round, that is still in
+// play. It is side itself if nobody else is.
+func (s *State) nextInPlay(side Side) Side {
+	for i := 1; i <= MaxSides; i++ {
+		next := (side + Side(i)) % MaxSides
+		if s.InPlay(next) {
+			return next
+		}
+	}
+	return side
+}
+
+// moveBall advances ball i by dt seconds, bouncing it off paddles and walls
+// at the exact time of impact.
+func (s *State) moveBall(i int, dt float64, events []Event) []Event {
+	b := &s.Balls[i]
+	remaining := dt
+	for bounce := 0; bounce < maxBounces && remaining > 0; bounce++ {
+		delta := Pair{X: b.Velocity.X * remaining, Y: b.Velocity.Y * remaining}
+
+		hit, kind, side, obstacle, ok := s.firstContact(b, delta)
+		if !ok {
+			b.Coord.X += delta.X
+			b.Coord.Y += delta.Y
+			return events
+		}
+		b.Coord.X += delta.X * hit.Time
+		b.Coord.Y += delta.Y * hit.Time
+		remaining -= remaining * hit.Time
+
+		switch kind {
+		case EventPaddleHit:
+			s.bouncePaddle(b, side, hit.Normal)
+			if hit.Normal.Dot(side.Inward()) > 0 {
+				s.speedUp(b, side)
+				s.stick(b, side)
+			}
+			s.Stats.Hits[side]++
+			s.Stats.Rally++
+			b.Hits++
+			b.LastHit = side
+		case EventWallBounce:
+			b.Velocity = Reflect(b.Velocity, hit.Normal)
+		case EventObstacleHit:
+			s.bounceObstacle(b, &s.Config.Arena.Obstacles[obstacle], hit.Normal)
+		}
+		events = append(events, Event{Kind: kind, Side: side, Ball: i})
+		if every := s.Config.MultiBall.EveryHits; kind == EventPaddleHit && every > 0 && s.Stats.Rally%every == 0 {
+			events = s.spawn(events)
+		}
+		if b.Stuck > 0 {
+			return events
+		}
+	}
+	return events
+}
+
+// collideBalls bounces touching balls off each other. They all weigh the
+// same, so an elastic collision just swaps their speeds along the line
+// between their centres.
+func (s *State) collideBalls(events []Event) []Event {
+	for i := range s.Balls {
+		for j := i + 1; j < MaxBalls; j++ {
+			a, b := &s.Balls[i], &s.Balls[j]
+			if !a.Live || !b.Live {
+				continue
+			}
+			d := Pair{X: b.Coord.X - a.Coord.X, Y: b.Coord.Y - a.Coord.Y}
+			dist := math.Hypot(d.X, d.Y)
+			if dist == 0 || dist >= a.Radius+b.Radius {
+				continue
+			}
+			normal := Pair{X: d.X / dist, Y: d.Y / dist}
+			// Balls already moving apart are left to separate.
+			closing := Pair{X: a.Velocity.X - b.Velocity.X, Y: a.Velocity.Y - b.Velocity.Y}.Dot(normal)
+			if closing <= 0 {
+				continue
+			}
+			a.Velocity.X -= closing * normal.X
+			a.Velocity.Y -= closing * normal.Y
+			b.Velocity.X += closing * normal.X
+			b.Velocity.Y += closing * normal.Y
+			s.keepCrossing(a)
+			s.keepCrossing(b)
+			events = append(events, Event{Kind: EventBallHit})
+		}
+	}
+	return events
+}
+
+// keepCrossing stops a ball knocked sideways by another from bouncing
+// between two walls forever: if only one axis has goals on it, the ball
+// keeps heading along it at half its base speed or more.
+func (s *State) keepCrossing(b *Ball) {
+	min := b.BaseSpeed / 2
+	across := s.InPlay(Left) || s.InPlay(Right)
+	down := s.InPlay(Top) || s.InPlay(Bottom)
+	switch {
+	case across && !down && math.Abs(b.Velocity.X) < min:
+		b.Velocity.X = math.Copysign(min, b.Velocity.X)
+	case down && !across && math.Abs(b.Velocity.Y) < min:
+		b.Velocity.Y = math.Copysign(min, b.Velocity.Y)
+	}
+}
+
+// firstContact finds what a ball moving by delta touches first. For an
+// obstacle it also returns which one.
+func (s *State) firstContact(b *Ball, delta Pair) (Hit, EventKind, Side, int, bool) {
+	var (
+		best     Hit
+		kind     EventKind
+		side     Side
+		obstacle int
+		found    bool
+	)
+	consider := func(hit Hit, k EventKind, sd Side, o int) {
+		// Contacts the ball is already leaving are ignored, otherwise a ball
+		// resting against a surface would bounce on it forever.
+		if hit.Normal.X*b.Velocity.X+hit.Normal.Y*b.Velocity.Y >= 0 {
+			return
+		}
+		if !found || hit.Time < best.Time {
+			best, kind, side, obstacle, found = hit, k, sd, o, true
+		}
+	}
+
+	// Sides with a paddle in play have a goal behind it, which may not take
+	// up the whole wall; the rest are walls.
+	arena := &s.Config.Arena
+	for sd := Side(0); sd < MaxSides; sd++ {
+		if s.InPlay(sd) {
+			if hit, ok := SweepCircleRect(b.Coord, b.Radius, delta, s.Paddle(sd).Rect); ok {
+				consider(hit, EventPaddleHit, sd, 0)
+			}
+			if lo, _ := arena.Mouth(sd); lo > 0 {
+				for _, post := range arena.posts(sd) {
+					if hit, ok := SweepCircleRect(b.Coord, b.Radius, delta, post); ok {
+						consider(hit, EventWallBounce, sd, 0)
+					}
+				}
+			}
+			continue
+		}
+		if t, ok := sweepWall(sd, b.Coord, b.Radius, delta); ok {
+			consider(Hit{Time: t, Normal: sd.Inward()}, EventWallBounce, sd, 0)
+		}
+	}
+	for i, o := range arena.Obstacles {
+		if o.Shape == ShapeNone {
+			continue
+		}
+		o = o.At(s.Stats.Ticks)
+		if hit, ok := o.sweep(b.Coord, b.Radius, delta); ok {
+			consider(hit, EventObstacleHit, 0, i)
+		}
+	}
+	return best, kind, side, obstacle, found
+}
+
+// sweepWall returns when a ball moving by delta touches side's wall.
+func sweepWall(side Side, center Pair, radius float64, delta Pair) (float64, bool) {
+	approach := -delta.Dot(side.Inward())
+	if approach <= 0 {
+		return 0, false
+	}
+	edge := WallDistance(side, center) - radius
+	if edge <= 0 {
+		return 0, true
+	}
+	t := edge / approach
+	return t, t <= 1
+}
+
+// GetBounceVelocity returns the velocity of a ball leaving the paddle. The
+// further from the paddle's centre the ball hits, the steeper and faster it
+// comes off.
+func GetBounceVelocity(paddle *Paddle, ball *Ball, maxSpeed float64) (float64, float64) {
+	relativeIntersect := paddle.Center().Y - ball.Coord.Y
+	normalizedRelativeIntersect := (relativeIntersect / (paddle.H / 2))
+	angle := normalizedRelativeIntersect * math.Pi / 4
+	return math.Abs(math.Cos(angle)) * maxSpeed * math.Abs(angle), -math.Sin(angle) * maxSpeed * math.Abs(angle)
+}
+
+// bouncePaddle sends the ball back off a paddle. Hits on the face use the
+// classic angle-from-centre bounce; hits on the paddle's ends just reflect.
+// Horizontal paddles work the same way with the arena transposed.
+func (s *State) bouncePaddle(b *Ball, side Side, normal Pair) {
+	inward := side.Inward()
+	if normal.Dot(inward) <= 0 {
+		b.Velocity = Reflect(b.Velocity, normal)
+		return
+	}
+
+	paddle, ball := *s.Paddle(side), *b
+	if side.Horizontal() {
+		paddle.Rect, ball = paddle.Transposed(), ball.Transposed()
+	}
+	out, across := GetBounceVelocity(&paddle, &ball, b.VelocityBounds.Y)
+	out = math.Max(out, b.VelocityBounds.X)
+
+	if side.Horizontal() {
+		b.Velocity = Pair{X: across, Y: out * inward.Y}
+	} else {
+		b.Velocity = Pair{X: out * inward.X, Y: across}
+	}
+}
+
+// Transposed swaps the ball's X and Y; see Pair.Transposed.
+func (b Ball) Transposed() Ball {
+	b.Coord, b.Velocity = b.Coord.Transposed(), b.Velocity.Transposed()
+	return b
+}
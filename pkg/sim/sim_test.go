@@ -0,0 +1,369 @@
+package sim
+
+import (
+	"math"
+	"testing"
+)
+
+// shot is a ball let into side's goal, last hit by hits's paddle if set.
+type shot struct {
+	side Side
+	hits *Side
+}
+
+func by(side Side) *Side {
+	return &side
+}
+
+// concede plays a ball straight into side's goal from just in front of it,
+// behind the paddle.
+func concede(t *testing.T, s *State, sh shot) {
+	t.Helper()
+	s.Mode = ModePlay
+	s.Balls = [MaxBalls]Ball{}
+	b := &s.Balls[0]
+	*b = s.newBall()
+	in := sh.side.Inward()
+	distance := s.Config.PaddleDistance / 2
+	b.Coord = Pair{X: ArenaWidth / 2, Y: ArenaHeight / 2}
+	switch sh.side {
+	case Left:
+		b.Coord.X = distance
+	case Right:
+		b.Coord.X = ArenaWidth - distance
+	case Top:
+		b.Coord.Y = distance
+	case Bottom:
+		b.Coord.Y = ArenaHeight - distance
+	}
+	b.Velocity = Pair{X: -in.X * b.BaseSpeed, Y: -in.Y * b.BaseSpeed}
+	if sh.hits != nil {
+		b.LastHit, b.Hits = *sh.hits, 1
+	}
+	for tick := 0; tick < TickRate; tick++ {
+		var events []Event
+		*s, events = Step(*s, Inputs{})
+		for _, e := range events {
+			if e.Kind == EventGoal {
+				if e.Side != sh.side {
+					t.Fatalf("ball went into side %d's goal, aimed at %d", e.Side, sh.side)
+				}
+				return
+			}
+		}
+	}
+	t.Fatalf("ball never went into side %d's goal", sh.side)
+}
+
+func TestScoring(t *testing.T) {
+	l, r := shot{side: Left}, shot{side: Right}
+	tests := []struct {
+		name    string
+		players int
+		rules   Rules
+		shots   []shot
+		score   [MaxSides]int
+		sets    [MaxSides]int
+		lives   [MaxSides]int
+		over    bool
+		winner  Side
+	}{
+		{
+			name:  "endless",
+			shots: []shot{l, l, l, l, l, l, l, l, l, l, l, l, l, l, l, r},
+			score: [MaxSides]int{1, 15},
+		},
+		{
+			name:   "first to three",
+			rules:  Rules{Points: 3},
+			shots:  []shot{r, l, l, r, l},
+			score:  [MaxSides]int{2, 3},
+			sets:   [MaxSides]int{0, 1},
+			over:   true,
+			winner: Right,
+		},
+		{
+			name:  "not ahead by two",
+			rules: Rules{Points: 3, WinBy: 2},
+			shots: []shot{l, r, l, r, l},
+			score: [MaxSides]int{2, 3},
+		},
+		{
+			name:   "ahead by two",
+			rules:  Rules{Points: 3, WinBy: 2},
+			shots:  []shot{l, r, l, r, l, r, r, r},
+			score:  [MaxSides]int{5, 3},
+			sets:   [MaxSides]int{1, 0},
+			over:   true,
+			winner: Left,
+		},
+		{
+			name:  "first set",
+			rules: Rules{Points: 2, Sets: 3},
+			shots: []shot{l, l, r},
+			score: [MaxSides]int{1, 0},
+			sets:  [MaxSides]int{0, 1},
+		},
+		{
+			name:   "best of three",
+			rules:  Rules{Points: 2, Sets: 3},
+			shots:  []shot{l, l, r, r, l, r, l},
+			score:  [MaxSides]int{1, 2},
+			sets:   [MaxSides]int{1, 2},
+			over:   true,
+			winner: Right,
+		},
+		{
+			name:   "lives",
+			rules:  Rules{Points: 1, Lives: 2},
+			shots:  []shot{l, r, r},
+			score:  [MaxSides]int{2, 1},
+			lives:  [MaxSides]int{1, 0},
+			over:   true,
+			winner: Left,
+		},
+		{
+			name:    "four players",
+			players: 4,
+			rules:   Rules{Points: 2},
+			shots: []shot{
+				{Top, by(Left)},
+				// Own goals and balls nobody has touched score nothing.
+				{Bottom, by(Bottom)},
+				{Right, nil},
+				{Bottom, by(Right)},
+				{Right, by(Left)},
+			},
+			score:  [MaxSides]int{2, 1, 0, 0},
+			sets:   [MaxSides]int{1, 0, 0, 0},
+			over:   true,
+			winner: Left,
+		},
+		{
+			name:    "one out",
+			players: 4,
+			rules:   Rules{Lives: 1},
+			shots:   []shot{{Top, by(Right)}},
+			score:   [MaxSides]int{0, 1, 0, 0},
+			lives:   [MaxSides]int{1, 1, 0, 1},
+		},
+		{
+			name:    "last one left",
+			players: 4,
+			rules:   Rules{Lives: 1},
+			shots:   []shot{{Top, by(Right)}, {Left, by(Left)}, {Bottom, by(Right)}},
+			score:   [MaxSides]int{0, 2, 0, 0},
+			lives:   [MaxSides]int{0, 1, 0, 0},
+			over:    true,
+			winner:  Right,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Players = test.players
+			config.Rules = test.rules
+			s := NewState(config, 1)
+			for i, sh := range test.shots {
+				if s.Mode == ModeOver {
+					t.Fatalf("match over after %d of %d goals", i, len(test.shots))
+				}
+				concede(t, &s, sh)
+			}
+			if s.Score != test.score || s.Sets != test.sets || s.Lives != test.lives {
+				t.Errorf("score %v, sets %v and lives %v, want %v, %v and %v",
+					s.Score, s.Sets, s.Lives, test.score, test.sets, test.lives)
+			}
+			if over := s.Mode == ModeOver; over != test.over || (over && s.Winner != test.winner) {
+				t.Errorf("mode %d with winner %d, want over %v with winner %d", s.Mode, s.Winner, test.over, test.winner)
+			}
+		})
+	}
+}
+
+// TestOutWallIsSolid checks a player who is out no longer lets in goals.
+func TestOutWallIsSolid(t *testing.T) {
+	config := DefaultConfig()
+	config.Players = MaxSides
+	config.Rules = Rules{Lives: 1}
+	s := NewState(config, 1)
+	concede(t, &s, shot{Top, by(Left)})
+	if s.InPlay(Top) {
+		t.Fatal("top still in play with no lives left")
+	}
+
+	s.Mode = ModePlay
+	s.Balls = [MaxBalls]Ball{}
+	b := &s.Balls[0]
+	*b = s.newBall()
+	b.Velocity = Pair{Y: -b.BaseSpeed}
+	// Long enough to get to the top wall and back, but not to the bottom.
+	for tick := 0; tick < TickRate; tick++ {
+		var events []Event
+		s, events = Step(s, Inputs{})
+		for _, e := range events {
+			if e.Kind == EventGoal {
+				t.Fatalf("goal against side %d", e.Side)
+			}
+		}
+	}
+	if b := s.Balls[0]; !b.Live || b.Velocity.Y <= 0 {
+		t.Errorf("ball %+v didn't bounce off the top wall", b)
+	}
+}
+
+// served starts a match with server to serve and steps until they have.
+func served(t *testing.T, config Config, seed int64, server Side) Ball {
+	t.Helper()
+	s := NewState(config, seed)
+	s.Server = server
+	s, _ = Step(s, Inputs{{Buttons: ButtonStart}})
+	for tick := 0; s.Mode != ModePlay; tick++ {
+		if tick > 10*TickRate {
+			t.Fatal("never served")
+		}
+		s, _ = Step(s, Inputs{})
+	}
+	return s.Balls[0]
+}
+
+func TestServeCone(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		cone float64
+		want float64
+	}{
+		{"default", DefaultConfig().Serve.Cone, DefaultConfig().Serve.Cone},
+		{"narrow", math.Pi / 12, math.Pi / 12},
+		{"straight", 0, 0},
+		{"too wide", math.Pi / 2, MaxServeCone},
+	} {
+		config := DefaultConfig()
+		config.Players = MaxSides
+		config.Serve.Cone = test.cone
+		var widest [2]float64
+		for seed := int64(1); seed <= 100; seed++ {
+			for _, server := range []Side{Left, Right, Top, Bottom} {
+				b := served(t, config, seed, server)
+				away := server.Inward()
+				along := b.Velocity.X*away.X + b.Velocity.Y*away.Y
+				across := b.Velocity.X*away.Y - b.Velocity.Y*away.X
+				if !near(along, b.BaseSpeed) {
+					t.Fatalf("%s: side %d served at %g across the arena, want %g", test.name, server, along, b.BaseSpeed)
+				}
+				angle := math.Atan2(across, along)
+				if math.Abs(angle) > test.want+1e-9 {
+					t.Errorf("%s: side %d served at %g radians, outside %g", test.name, server, angle, test.want)
+				}
+				if angle > 0 {
+					widest[0] = math.Max(widest[0], angle)
+				} else {
+					widest[1] = math.Max(widest[1], -angle)
+				}
+			}
+		}
+		// Serves spread right across the cone, both ways.
+		if widest[0] < test.want*0.9 || widest[1] < test.want*0.9 {
+			t.Errorf("%s: widest serves %v, want near %g", test.name, widest, test.want)
+		}
+	}
+}
+
+func TestServeSeeded(t *testing.T) {
+	config := DefaultConfig()
+	first := served(t, config, 5, Left)
+	if again := served(t, config, 5, Left); again != first {
+		t.Errorf("the same seed served %+v and %+v", first.Velocity, again.Velocity)
+	}
+	differ := false
+	for seed := int64(6); seed < 10; seed++ {
+		if served(t, config, seed, Left).Velocity != first.Velocity {
+			differ = true
+		}
+	}
+	if !differ {
+		t.Error("every seed serves the same way")
+	}
+}
+
+func TestServer(t *testing.T) {
+	l, r := shot{side: Left}, shot{side: Right}
+	for _, test := range []struct {
+		name    string
+		rule    ServeRule
+		players int
+		lives   int
+		shots   []shot
+		servers []Side
+	}{
+		{"alternate", ServeAlternate, 2, 0, []shot{l, l, r}, []Side{Right, Left, Right}},
+		{"loser", ServeLoser, 2, 0, []shot{l, l, r}, []Side{Left, Left, Right}},
+		{"winner", ServeWinner, 2, 0, []shot{l, l, r}, []Side{Right, Right, Left}},
+		{
+			"alternate round four", ServeAlternate, 4, 0,
+			[]shot{l, l, l, l},
+			[]Side{Right, Top, Bottom, Left},
+		},
+		{
+			"alternate past those out", ServeAlternate, 4, 1,
+			[]shot{{Top, by(Left)}, {Left, by(Right)}},
+			[]Side{Right, Bottom},
+		},
+		{
+			"loser out", ServeLoser, 4, 1,
+			[]shot{{Top, by(Left)}, {Left, by(Right)}},
+			[]Side{Bottom, Right},
+		},
+		{
+			"winner of an own goal", ServeWinner, 4, 0,
+			[]shot{{Top, by(Left)}, {Bottom, by(Bottom)}, {Right, nil}},
+			[]Side{Left, Bottom, Right},
+		},
+	} {
+		config := DefaultConfig()
+		config.Players = test.players
+		config.Rules = Rules{Lives: test.lives}
+		config.Serve.Rule = test.rule
+		s := NewState(config, 1)
+		for i, sh := range test.shots {
+			concede(t, &s, sh)
+			if s.Server != test.servers[i] {
+				t.Errorf("%s: side %d serves after goal %d, want %d", test.name, s.Server, i+1, test.servers[i])
+			}
+		}
+	}
+}
+
+func TestCollideBalls(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		offset Pair
+		a, b   Pair
+		wantA  Pair
+		wantB  Pair
+	}{
+		{"head on", Pair{X: 1}, Pair{X: 2.4}, Pair{X: -1.6}, Pair{X: -1.6}, Pair{X: 2.4}},
+		{"catching up", Pair{X: 1}, Pair{X: 3}, Pair{X: 1.5}, Pair{X: 1.5}, Pair{X: 3}},
+		{"glancing", Pair{Y: 1}, Pair{X: 2.4, Y: 1}, Pair{X: -2.4, Y: -1}, Pair{X: 2.4, Y: -1}, Pair{X: -2.4, Y: 1}},
+		{"moving apart", Pair{X: 1}, Pair{X: -2}, Pair{X: 2}, Pair{X: -2}, Pair{X: 2}},
+	} {
+		s := NewState(DefaultConfig(), 1)
+		s.Mode = ModePlay
+		a, b := &s.Balls[0], &s.Balls[1]
+		*b = s.newBall()
+		// Overlapping a little along offset.
+		gap := (a.Radius + b.Radius) * 0.9
+		b.Coord = Pair{X: a.Coord.X + test.offset.X*gap, Y: a.Coord.Y + test.offset.Y*gap}
+		a.Velocity, b.Velocity = test.a, test.b
+		s.collideBalls(nil)
+		if !near(a.Velocity.X, test.wantA.X) || !near(a.Velocity.Y, test.wantA.Y) ||
+			!near(b.Velocity.X, test.wantB.X) || !near(b.Velocity.Y, test.wantB.Y) {
+			t.Errorf("%s: velocities %+v and %+v, want %+v and %+v", test.name, a.Velocity, b.Velocity, test.wantA, test.wantB)
+		}
+		before := Pair{X: test.a.X + test.b.X, Y: test.a.Y + test.b.Y}
+		after := Pair{X: a.Velocity.X + b.Velocity.X, Y: a.Velocity.Y + b.Velocity.Y}
+		if !near(before.X, after.X) || !near(before.Y, after.Y) {
+			t.Errorf("%s: momentum went from %+v to %+v", test.name, before, after)
+		}
+	}
+}
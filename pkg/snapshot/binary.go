@@ -0,0 +1,117 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+// The binary form writes the fields of a snapshot in declaration order with
+// no names or type information, which is why any change to the layout of
+// sim.State needs a new Version. Floats take eight bytes, integers are
+// varints and booleans one byte.
+
+func encodeValue(buf *bytes.Buffer, v reflect.Value) error {
+	var scratch [binary.MaxVarintLen64]byte
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := encodeValue(buf, v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeValue(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		buf.Write(scratch[:binary.PutUvarint(scratch[:], uint64(v.Len()))])
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeValue(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Float64:
+		binary.BigEndian.PutUint64(scratch[:], math.Float64bits(v.Float()))
+		buf.Write(scratch[:8])
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf.Write(scratch[:binary.PutVarint(scratch[:], v.Int())])
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		buf.Write(scratch[:binary.PutUvarint(scratch[:], v.Uint())])
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	default:
+		return fmt.Errorf("can't encode %s", v.Type())
+	}
+	return nil
+}
+
+var errTruncated = errors.New("truncated snapshot")
+
+func decodeValue(r *bytes.Reader, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := decodeValue(r, v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := decodeValue(r, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return errTruncated
+		}
+		if n > uint64(r.Len()) {
+			return errTruncated
+		}
+		v.Set(reflect.MakeSlice(v.Type(), int(n), int(n)))
+		for i := 0; i < int(n); i++ {
+			if err := decodeValue(r, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Float64:
+		var b [8]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return errTruncated
+		}
+		v.SetFloat(math.Float64frombits(binary.BigEndian.Uint64(b[:])))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := binary.ReadVarint(r)
+		if err != nil {
+			return errTruncated
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return errTruncated
+		}
+		v.SetUint(n)
+	case reflect.Bool:
+		b, err := r.ReadByte()
+		if err != nil {
+			return errTruncated
+		}
+		v.SetBool(b != 0)
+	default:
+		return fmt.Errorf("can't decode %s", v.Type())
+	}
+	return nil
+}
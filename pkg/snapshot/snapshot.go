@@ -0,0 +1,122 @@
+// Package snapshot saves and restores the full state of a match, either as
+// JSON, which is easy to read and edit when chasing a bug, or in a compact
+// binary form.
+package snapshot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+// Version is bumped whenever sim.State changes in a way older snapshots
+// can't be read into.
+const Version = 7
+
+// magic starts every binary snapshot.
+var magic = []byte("PONGSNAP")
+
+type Snapshot struct {
+	Version int
+	Seed    int64
+	State   sim.State
+}
+
+func New(state sim.State, seed int64) *Snapshot {
+	return &Snapshot{Version: Version, Seed: seed, State: state}
+}
+
+func EncodeJSON(s *Snapshot) ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// EncodeBinary encodes the snapshot as the magic bytes, a two byte version
+// and the fields of the snapshot.
+func EncodeBinary(s *Snapshot) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(magic)
+	binary.Write(&buf, binary.BigEndian, uint16(s.Version))
+	if err := encodeValue(&buf, reflect.ValueOf(s).Elem()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode reads a snapshot in either format.
+func Decode(data []byte) (*Snapshot, error) {
+	var s Snapshot
+	if bytes.HasPrefix(data, magic) {
+		data = data[len(magic):]
+		if len(data) < 2 {
+			return nil, errors.New("truncated snapshot")
+		}
+		if v := int(binary.BigEndian.Uint16(data)); v != Version {
+			return nil, versionError(v)
+		}
+		if err := decodeValue(bytes.NewReader(data[2:]), reflect.ValueOf(&s).Elem()); err != nil {
+			return nil, err
+		}
+		return &s, nil
+	}
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Version != Version {
+		return nil, versionError(s.Version)
+	}
+	return &s, nil
+}
+
+func versionError(v int) error {
+	return fmt.Errorf("snapshot version %d, this build reads version %d", v, Version)
+}
+
+// Save writes the snapshot as JSON if path ends in .json, and in the binary
+// form otherwise.
+func Save(path string, s *Snapshot) error {
+	var data []byte
+	var err error
+	if filepath.Ext(path) == ".json" {
+		data, err = EncodeJSON(s)
+		data = append(data, '\n')
+	} else {
+		data, err = EncodeBinary(s)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func Load(path string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// DefaultPath is where the in-game quick save is kept when no path is given.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pong", "quicksave.snap"), nil
+}
@@ -0,0 +1,160 @@
+package snapshot
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fabianvf/pong-golang/pkg/control"
+	"github.com/fabianvf/pong-golang/pkg/level"
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+const seed = 7
+
+// midMatch plays two computer players on a level with moving obstacles,
+// extra balls and every power-up until all of them are in use.
+func midMatch(t *testing.T) sim.State {
+	t.Helper()
+	l, err := level.Find("Sweeper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := sim.DefaultConfig()
+	if config.Arena, err = l.Arena(); err != nil {
+		t.Fatal(err)
+	}
+	config.MultiBall = sim.MultiBall{Balls: 3, Interval: 2}
+	config.PowerUps = sim.PowerUps{Interval: 1, Duration: 10}
+	for i := range config.PowerUps.Kinds {
+		config.PowerUps.Kinds[i] = true
+	}
+
+	s := sim.NewState(config, seed)
+	left, right := control.NewAI(control.Hard, seed), control.NewAI(control.Hard, seed+1)
+	for tick := 0; tick < sim.TickRate*60; tick++ {
+		in := sim.Inputs{
+			sim.Left:  left.Intent(&s, sim.Left),
+			sim.Right: right.Intent(&s, sim.Right),
+		}
+		if tick == 0 {
+			in[sim.Left].Buttons |= sim.ButtonStart
+		}
+		s, _ = sim.Step(s, in)
+		if s.Mode == sim.ModePlay && live(s) > 1 && s.PowerUps[0].Live && effects(s) > 0 {
+			return s
+		}
+	}
+	t.Fatal("never got extra balls, a power-up and an effect at once")
+	return s
+}
+
+func live(s sim.State) int {
+	n := 0
+	for _, b := range s.Balls {
+		if b.Live {
+			n++
+		}
+	}
+	return n
+}
+
+func effects(s sim.State) int {
+	n := 0
+	for _, side := range s.Effects {
+		for _, e := range side {
+			n += e.Ticks
+		}
+	}
+	return n
+}
+
+func TestRoundTrip(t *testing.T) {
+	state := midMatch(t)
+	formats := []struct {
+		name   string
+		encode func(*Snapshot) ([]byte, error)
+	}{
+		{"json", EncodeJSON},
+		{"binary", EncodeBinary},
+	}
+	for _, f := range formats {
+		data, err := f.encode(New(state, seed))
+		if err != nil {
+			t.Fatalf("%s: %v", f.name, err)
+		}
+		s, err := Decode(data)
+		if err != nil {
+			t.Fatalf("%s: %v", f.name, err)
+		}
+		if s.Seed != seed || !reflect.DeepEqual(s.State, state) {
+			t.Errorf("%s: snapshot changed on the way through", f.name)
+			continue
+		}
+
+		// Both copies of the match should carry on exactly alike.
+		a, b := state, s.State
+		for tick := 0; tick < sim.TickRate*5; tick++ {
+			in := sim.Inputs{sim.Left: {Axis: 1}, sim.Right: {Axis: -0.5}}
+			a, _ = sim.Step(a, in)
+			b, _ = sim.Step(b, in)
+		}
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("%s: restored match went differently", f.name)
+		}
+	}
+}
+
+func TestDecodeBad(t *testing.T) {
+	state := sim.NewState(sim.DefaultConfig(), seed)
+	bin, err := EncodeBinary(New(state, seed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := EncodeJSON(New(state, seed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrongMagic := append([]byte(nil), bin...)
+	wrongMagic[0] = 'X'
+	wrongVersion := append([]byte(nil), bin...)
+	binary.BigEndian.PutUint16(wrongVersion[len(magic):], Version+1)
+	old := New(state, seed)
+	old.Version = Version - 1
+	oldJSON, err := EncodeJSON(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, ""},
+		{"wrong magic", wrongMagic, ""},
+		{"wrong version", wrongVersion, "version"},
+		{"wrong json version", oldJSON, "version"},
+		{"no version", magic, "truncated"},
+		{"truncated json", js[:len(js)/2], ""},
+	}
+	for _, test := range tests {
+		s, err := Decode(test.data)
+		if err == nil {
+			t.Errorf("%s (%d bytes): decoded %+v", test.name, len(test.data), s)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s (%d bytes): got %v, want an error about %q", test.name, len(test.data), err, test.want)
+		}
+	}
+
+	// Cut the binary snapshot short everywhere after the version.
+	for n := len(magic) + 2; n < len(bin); n++ {
+		if s, err := Decode(bin[:n]); err != errTruncated {
+			t.Errorf("cut to %d of %d bytes: got %+v, %v", n, len(bin), s, err)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Package spectate streams a match to any number of read-only viewers, for
+// example to put it up on a big screen. Viewers are sent a snapshot as soon
+// as they connect, so they can join at any time, and then snapshots at Rate,
+// lower than the tick rate, which they interpolate between.
+//
+// Snapshots use the pkg/server format, one JSON message per line.
+package spectate
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/fabianvf/pong-golang/pkg/server"
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+const (
+	// Rate is how many snapshots a second viewers are sent.
+	Rate = 20
+	// Delay is how many ticks behind the match viewers draw it.
+	Delay = 3 * sim.TickRate / Rate
+	// sendQueue is how many snapshots may wait for a slow viewer before
+	// newer ones are dropped.
+	sendQueue = 8
+)
+
+// Publisher sends snapshots of a match to everyone watching it.
+type Publisher struct {
+	listener net.Listener
+	tick     int
+	events   []sim.Event
+
+	mu       sync.Mutex
+	latest   []byte
+	viewers  map[*viewer]bool
+	isClosed bool
+}
+
+type viewer struct {
+	conn net.Conn
+	send chan []byte
+}
+
+// Listen starts accepting viewers on addr.
+func Listen(addr string) (*Publisher, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	p := &Publisher{listener: l, viewers: make(map[*viewer]bool)}
+	go p.accept()
+	log.Printf("spectators can watch on %s", l.Addr())
+	return p, nil
+}
+
+// Addr is the address viewers connect to.
+func (p *Publisher) Addr() net.Addr {
+	return p.listener.Addr()
+}
+
+func (p *Publisher) accept() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		v := &viewer{conn: conn, send: make(chan []byte, sendQueue)}
+		p.mu.Lock()
+		if p.isClosed {
+			p.mu.Unlock()
+			conn.Close()
+			return
+		}
+		p.viewers[v] = true
+		if p.latest != nil {
+			v.send <- p.latest
+		}
+		p.mu.Unlock()
+		go p.serve(v)
+	}
+}
+
+// serve writes snapshots to v until it goes away. Anything a viewer sends
+// is ignored; reading only notices when they hang up.
+func (p *Publisher) serve(v *viewer) {
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			if _, err := v.conn.Read(buf); err != nil {
+				p.drop(v)
+				return
+			}
+		}
+	}()
+	for data := range v.send {
+		if _, err := v.conn.Write(data); err != nil {
+			p.drop(v)
+		}
+	}
+	v.conn.Close()
+}
+
+func (p *Publisher) drop(v *viewer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.viewers[v] {
+		delete(p.viewers, v)
+		close(v.send)
+	}
+}
+
+// Publish is called after every tick with the new state and the tick's
+// events. Every TickRate/Rate ticks a snapshot goes out to the viewers.
+func (p *Publisher) Publish(s sim.State, events []sim.Event) {
+	p.events = append(p.events, events...)
+	p.tick++
+	if p.tick%(sim.TickRate/Rate) != 0 {
+		return
+	}
+	data, err := json.Marshal(server.ServerMessage{Snapshot: &server.Snapshot{
+		Tick:   p.tick,
+		State:  s,
+		Events: p.events,
+	}})
+	p.events = nil
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.latest = data
+	for v := range p.viewers {
+		select {
+		case v.send <- data:
+		default:
+		}
+	}
+}
+
+// Viewers is the number of people watching.
+func (p *Publisher) Viewers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.viewers)
+}
+
+// Close stops accepting viewers and disconnects the ones watching.
+func (p *Publisher) Close() error {
+	err := p.listener.Close()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.isClosed = true
+	for v := range p.viewers {
+		delete(p.viewers, v)
+		close(v.send)
+	}
+	return err
+}
+
+// Viewer watches a match streamed by a Publisher.
+type Viewer struct {
+	conn   server.Conn
+	mu     sync.Mutex
+	buffer server.Buffer
+	err    error
+}
+
+// Watch connects to the publisher at addr.
+func Watch(addr string) (*Viewer, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	v := &Viewer{conn: server.NewLineConn(conn), buffer: server.Buffer{Delay: Delay}}
+	go v.read()
+	return v, nil
+}
+
+func (v *Viewer) read() {
+	for {
+		data, err := v.conn.ReadMessage()
+		if err != nil {
+			v.fail(err)
+			return
+		}
+		var m server.ServerMessage
+		if err := json.Unmarshal(data, &m); err != nil {
+			v.fail(err)
+			return
+		}
+		if m.Snapshot != nil {
+			v.mu.Lock()
+			v.buffer.Add(*m.Snapshot, time.Now())
+			v.mu.Unlock()
+		}
+	}
+}
+
+func (v *Viewer) fail(err error) {
+	v.mu.Lock()
+	v.err = err
+	v.mu.Unlock()
+}
+
+// State returns the match as it should be drawn at now, or false if nothing
+// has been received yet.
+func (v *Viewer) State(now time.Time) (sim.State, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.buffer.State(now)
+}
+
+// Events returns the events received since the last call.
+func (v *Viewer) Events() []sim.Event {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.buffer.Events()
+}
+
+// Err returns why the stream ended, if it has.
+func (v *Viewer) Err() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.err
+}
+
+func (v *Viewer) Close() error {
+	return v.conn.Close()
+}
@@ -0,0 +1,115 @@
+package spectate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+const every = sim.TickRate / Rate
+
+// waitFor polls cond until it holds, failing if it doesn't soon.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// latest is the tick of the newest snapshot v has, or zero for none.
+func latest(v *Viewer) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, _ := v.buffer.Latest()
+	return s.Tick
+}
+
+func watch(t *testing.T, p *Publisher, watching int) *Viewer {
+	t.Helper()
+	v, err := Watch(p.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the viewer to be let in", func() bool { return p.Viewers() == watching })
+	return v
+}
+
+func TestPublish(t *testing.T) {
+	p, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	s := sim.NewState(sim.DefaultConfig(), 1)
+	tick := 0
+	publish := func() {
+		tick++
+		s.Stats.Ticks = tick
+		p.Publish(s, []sim.Event{{Kind: sim.EventPaddleHit}})
+	}
+
+	early := watch(t, p, 1)
+	defer early.Close()
+	for tick < 2*every+1 {
+		publish()
+	}
+	waitFor(t, "the first snapshots", func() bool { return latest(early) == 2*every })
+
+	// Someone joining part way through is sent the latest snapshot straight
+	// away rather than waiting for the next.
+	late := watch(t, p, 2)
+	defer late.Close()
+	waitFor(t, "the latest snapshot", func() bool { return latest(late) == 2*every })
+	if st, ok := late.State(time.Now()); !ok || st.Stats.Ticks != 2*every {
+		t.Errorf("late viewer sees tick %d, %v", st.Stats.Ticks, ok)
+	}
+	later := watch(t, p, 3)
+	defer later.Close()
+	waitFor(t, "the latest snapshot", func() bool { return latest(later) == 2*every })
+
+	viewers := []*Viewer{early, late, later}
+	for tick < 5*every {
+		publish()
+		if tick%every != 0 {
+			// Give a stray snapshot a moment to show up.
+			time.Sleep(2 * time.Millisecond)
+			for i, v := range viewers {
+				if got := latest(v); got != tick-tick%every {
+					t.Errorf("viewer %d has a snapshot for tick %d at tick %d", i, got, tick)
+				}
+			}
+			continue
+		}
+		for _, v := range viewers {
+			waitFor(t, "the next snapshot", func() bool { return latest(v) == tick })
+		}
+	}
+	// Events come batched into the snapshots, so joining late misses those
+	// from before the snapshot sent on joining.
+	if n := len(early.Events()); n != 5*every {
+		t.Errorf("early viewer got %d events, want %d", n, 5*every)
+	}
+	if n := len(later.Events()); n != 4*every {
+		t.Errorf("later viewer got %d events, want %d", n, 4*every)
+	}
+
+	late.Close()
+	waitFor(t, "the viewer who left to be dropped", func() bool { return p.Viewers() == 2 })
+	for tick < 6*every {
+		publish()
+	}
+	for _, v := range []*Viewer{early, later} {
+		waitFor(t, "a snapshot after someone left", func() bool { return latest(v) == 6*every })
+		if err := v.Err(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	p.Close()
+	waitFor(t, "the viewers to be disconnected", func() bool { return early.Err() != nil && later.Err() != nil })
+}
@@ -0,0 +1,343 @@
+// Package tournament runs a bracket of local matches: single or double
+// elimination, or a round robin. It only keeps score; the caller plays each
+// match and records who won. Progress is saved after every match so a
+// closed session picks up at the next one.
+package tournament
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+type Format string
+
+const (
+	SingleElimination Format = "single"
+	DoubleElimination Format = "double"
+	RoundRobin        Format = "round-robin"
+)
+
+// Bye stands in for the missing opponent of a player who sits a round out.
+const Bye = -1
+
+// Brackets of a double elimination tournament. Single elimination only
+// labels its final.
+const (
+	BracketWinners = "winners"
+	BracketLosers  = "losers"
+	BracketFinal   = "final"
+)
+
+// Match is one game between two players, given as indexes into Players.
+// Points are each player's points over the whole match.
+type Match struct {
+	Round   int
+	Bracket string `json:",omitempty"`
+	Players [2]int
+	Played  bool
+	Winner  int
+	Points  [2]int
+}
+
+// Tournament is the whole event. Matches grows as elimination rounds are
+// drawn; a round robin is drawn in full up front.
+type Tournament struct {
+	Format  Format
+	Players []string
+	Rules   sim.Rules
+	Matches []Match
+}
+
+// Standing is how a player is doing, for tables and tie-breaks.
+type Standing struct {
+	Player int
+	Name   string
+	Wins   int
+	Losses int
+	// Difference is points won less points lost.
+	Difference int
+}
+
+func New(format Format, players []string, rules sim.Rules) (*Tournament, error) {
+	if len(players) < 2 {
+		return nil, errors.New("a tournament needs at least two players")
+	}
+	seen := map[string]bool{}
+	for _, p := range players {
+		if p == "" || seen[p] {
+			return nil, fmt.Errorf("player names must be unique and not empty: %q", p)
+		}
+		seen[p] = true
+	}
+	t := &Tournament{Format: format, Players: players, Rules: rules}
+	switch format {
+	case SingleElimination, DoubleElimination:
+		t.draw()
+	case RoundRobin:
+		t.drawRoundRobin()
+	default:
+		return nil, fmt.Errorf("unknown tournament format %q", format)
+	}
+	return t, nil
+}
+
+// Next returns the next match to play, or nil once the tournament is over.
+func (t *Tournament) Next() *Match {
+	for i := range t.Matches {
+		if !t.Matches[i].Played {
+			return &t.Matches[i]
+		}
+	}
+	return nil
+}
+
+// Record settles m, which must be the match Next returned, and draws the
+// next round if that finishes the current one.
+func (t *Tournament) Record(m *Match, winner sim.Side, points [2]int) error {
+	if m != t.Next() {
+		return errors.New("only the next match can be recorded")
+	}
+	m.Played = true
+	m.Winner = m.Players[winner]
+	m.Points = points
+	if t.Format != RoundRobin {
+		t.draw()
+	}
+	return nil
+}
+
+func (t *Tournament) Done() bool {
+	return t.Next() == nil
+}
+
+// Champion is the winner, once the tournament is over.
+func (t *Tournament) Champion() (string, bool) {
+	if !t.Done() {
+		return "", false
+	}
+	return t.Standings()[0].Name, true
+}
+
+// Standings ranks the players: by fewest losses for elimination formats and
+// most wins for a round robin, then by points difference.
+func (t *Tournament) Standings() []Standing {
+	standings := make([]Standing, len(t.Players))
+	for i, name := range t.Players {
+		standings[i] = Standing{Player: i, Name: name}
+	}
+	for _, m := range t.Matches {
+		if !m.Played || m.Players[1] == Bye {
+			continue
+		}
+		for side, p := range m.Players {
+			s := &standings[p]
+			if p == m.Winner {
+				s.Wins++
+			} else {
+				s.Losses++
+			}
+			s.Difference += m.Points[side] - m.Points[1-side]
+		}
+	}
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if t.Format == RoundRobin && a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		if t.Format != RoundRobin && a.Losses != b.Losses {
+			return a.Losses < b.Losses
+		}
+		return a.Difference > b.Difference
+	})
+	return standings
+}
+
+// lives is how many losses knock a player out.
+func (t *Tournament) lives() int {
+	if t.Format == DoubleElimination {
+		return 2
+	}
+	return 1
+}
+
+// draw adds the next elimination round once every match so far is played.
+// Players are grouped by losses, so a double elimination has a winners and
+// a losers bracket, and paired in the order they were entered. Whoever is
+// left over gets a bye, preferring players who haven't had one. The losers
+// bracket plays its own round after the winners bracket's, and a bracket
+// down to one player waits for the other to catch up.
+func (t *Tournament) draw() {
+	if t.Next() != nil {
+		return
+	}
+	losses := make([]int, len(t.Players))
+	byes := make([]int, len(t.Players))
+	round := 0
+	for _, m := range t.Matches {
+		round = m.Round
+		if m.Players[1] == Bye {
+			byes[m.Players[0]]++
+			continue
+		}
+		for _, p := range m.Players {
+			if p != m.Winner {
+				losses[p]++
+			}
+		}
+	}
+	round++
+
+	var groups [2][]int
+	alive := 0
+	for p, l := range losses {
+		if l < t.lives() {
+			groups[l] = append(groups[l], p)
+			alive++
+		}
+	}
+	if alive < 2 {
+		return
+	}
+	if alive == 2 {
+		final := append(groups[0], groups[1]...)
+		t.Matches = append(t.Matches, Match{Round: round, Bracket: BracketFinal, Players: [2]int{final[0], final[1]}})
+		return
+	}
+
+	drawn := false
+	for l, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		if drawn {
+			round++
+		}
+		drawn = true
+		bracket := ""
+		if t.Format == DoubleElimination {
+			bracket = BracketWinners
+			if l == 1 {
+				bracket = BracketLosers
+			}
+		}
+		if len(group)%2 == 1 {
+			sitter := len(group) - 1
+			for i := len(group) - 1; i >= 0; i-- {
+				if byes[group[i]] < byes[group[sitter]] {
+					sitter = i
+				}
+			}
+			t.Matches = append(t.Matches, Match{
+				Round:   round,
+				Bracket: bracket,
+				Players: [2]int{group[sitter], Bye},
+				Played:  true,
+				Winner:  group[sitter],
+			})
+			group = append(group[:sitter:sitter], group[sitter+1:]...)
+		}
+		for i := 0; i+1 < len(group); i += 2 {
+			t.Matches = append(t.Matches, Match{Round: round, Bracket: bracket, Players: [2]int{group[i], group[i+1]}})
+		}
+	}
+}
+
+// drawRoundRobin schedules every pairing with the circle method, so each
+// round everyone plays at most once.
+func (t *Tournament) drawRoundRobin() {
+	seats := make([]int, len(t.Players))
+	for i := range seats {
+		seats[i] = i
+	}
+	if len(seats)%2 == 1 {
+		seats = append(seats, Bye)
+	}
+	n := len(seats)
+	for round := 1; round < n; round++ {
+		for i := 0; i < n/2; i++ {
+			a, b := seats[i], seats[n-1-i]
+			if a == Bye || b == Bye {
+				continue
+			}
+			t.Matches = append(t.Matches, Match{Round: round, Players: [2]int{a, b}})
+		}
+		// Keep the first seat fixed and rotate the rest.
+		last := seats[n-1]
+		copy(seats[2:], seats[1:n-1])
+		seats[1] = last
+	}
+}
+
+// Save writes the tournament as JSON. It writes to a temporary file first
+// so a crash part way through never leaves a broken save behind.
+func Save(path string, t *Tournament) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func Load(path string) (*Tournament, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var t Tournament
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := t.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &t, nil
+}
+
+// validate checks a loaded tournament makes sense, so a hand edited or
+// corrupt save is an error rather than a crash part way through.
+func (t *Tournament) validate() error {
+	switch t.Format {
+	case SingleElimination, DoubleElimination, RoundRobin:
+	default:
+		return fmt.Errorf("unknown tournament format %q", t.Format)
+	}
+	if len(t.Players) < 2 {
+		return errors.New("a tournament needs at least two players")
+	}
+	player := func(p int) bool { return p >= 0 && p < len(t.Players) }
+	for i, m := range t.Matches {
+		a, b := m.Players[0], m.Players[1]
+		switch {
+		case !player(a) || (!player(b) && b != Bye) || a == b:
+			return fmt.Errorf("match %d: bad players %v", i+1, m.Players)
+		case b == Bye && (!m.Played || m.Winner != a):
+			return fmt.Errorf("match %d: a bye must be won by the player sitting out", i+1)
+		case m.Played && m.Winner != a && m.Winner != b:
+			return fmt.Errorf("match %d: winner %d didn't play", i+1, m.Winner)
+		}
+	}
+	return nil
+}
+
+// DefaultPath is where a tournament is saved when no path is given.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pong", "tournament.json"), nil
+}
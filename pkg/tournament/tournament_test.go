@@ -0,0 +1,228 @@
+package tournament
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fabianvf/pong-golang/pkg/sim"
+)
+
+func names(n int) []string {
+	players := make([]string, n)
+	for i := range players {
+		players[i] = fmt.Sprintf("p%d", i)
+	}
+	return players
+}
+
+// playOut records every match with a random winner, failing if it goes on
+// far longer than any bracket could.
+func playOut(t *testing.T, tr *Tournament, seed int64) {
+	t.Helper()
+	r := rand.New(rand.NewSource(seed))
+	for i := 0; !tr.Done(); i++ {
+		if i > 4*len(tr.Players) {
+			t.Fatalf("still going after %d matches", i)
+		}
+		m := tr.Next()
+		if err := tr.Record(m, sim.Side(r.Intn(2)), [2]int{r.Intn(12), r.Intn(12)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkRounds fails if anyone is down to play twice in a round or a round
+// mixes brackets.
+func checkRounds(t *testing.T, tr *Tournament) {
+	t.Helper()
+	type seat struct{ round, player int }
+	seen := map[seat]bool{}
+	brackets := map[int]string{}
+	for _, m := range tr.Matches {
+		for _, p := range m.Players {
+			if p == Bye {
+				continue
+			}
+			if seen[seat{m.Round, p}] {
+				t.Errorf("%s plays twice in round %d", tr.Players[p], m.Round)
+			}
+			seen[seat{m.Round, p}] = true
+		}
+		if b, ok := brackets[m.Round]; ok && b != m.Bracket {
+			t.Errorf("round %d has %s and %s bracket matches", m.Round, b, m.Bracket)
+		}
+		brackets[m.Round] = m.Bracket
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	for n := 2; n <= 9; n++ {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			tr, err := New(RoundRobin, names(n), sim.Rules{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := n * (n - 1) / 2; len(tr.Matches) != want {
+				t.Fatalf("%d matches, want %d", len(tr.Matches), want)
+			}
+			met := map[[2]int]bool{}
+			for _, m := range tr.Matches {
+				a, b := m.Players[0], m.Players[1]
+				if a > b {
+					a, b = b, a
+				}
+				if met[[2]int{a, b}] {
+					t.Errorf("%v meet twice", m.Players)
+				}
+				met[[2]int{a, b}] = true
+			}
+			checkRounds(t, tr)
+			playOut(t, tr, int64(n))
+			wins := 0
+			for _, s := range tr.Standings() {
+				wins += s.Wins
+			}
+			if wins != len(tr.Matches) {
+				t.Errorf("%d wins over %d matches", wins, len(tr.Matches))
+			}
+		})
+	}
+}
+
+func TestElimination(t *testing.T) {
+	for _, format := range []Format{SingleElimination, DoubleElimination} {
+		for n := 2; n <= 9; n++ {
+			for seed := int64(0); seed < 20; seed++ {
+				t.Run(fmt.Sprintf("%s/%d/%d", format, n, seed), func(t *testing.T) {
+					tr, err := New(format, names(n), sim.Rules{})
+					if err != nil {
+						t.Fatal(err)
+					}
+					playOut(t, tr, seed)
+					checkRounds(t, tr)
+
+					// Pairings within a bracket are between players with
+					// the same number of losses so far.
+					losses := make([]int, n)
+					for _, m := range tr.Matches {
+						a, b := m.Players[0], m.Players[1]
+						if b == Bye {
+							continue
+						}
+						if m.Bracket != BracketFinal && losses[a] != losses[b] {
+							t.Errorf("round %d pairs %d and %d losses", m.Round, losses[a], losses[b])
+						}
+						if losses[a] >= tr.lives() || losses[b] >= tr.lives() {
+							t.Errorf("round %d has a knocked out player", m.Round)
+						}
+						for _, p := range m.Players {
+							if p != m.Winner {
+								losses[p]++
+							}
+						}
+					}
+
+					out := 0
+					for _, l := range losses {
+						if l >= tr.lives() {
+							out++
+						}
+					}
+					if out != n-1 {
+						t.Errorf("%d of %d players knocked out", out, n)
+					}
+					champion, ok := tr.Champion()
+					if !ok || losses[tr.Standings()[0].Player] >= tr.lives() || champion != tr.Standings()[0].Name {
+						t.Errorf("champion %q, standings %+v", champion, tr.Standings())
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestDoubleEliminationBrackets(t *testing.T) {
+	tr, err := New(DoubleElimination, names(4), sim.Rules{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first player entered always wins.
+	for !tr.Done() {
+		m := tr.Next()
+		winner := sim.Left
+		if m.Players[1] < m.Players[0] {
+			winner = sim.Right
+		}
+		if err := tr.Record(m, winner, [2]int{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []Match{
+		{Round: 1, Bracket: BracketWinners, Players: [2]int{0, 1}},
+		{Round: 1, Bracket: BracketWinners, Players: [2]int{2, 3}},
+		{Round: 2, Bracket: BracketWinners, Players: [2]int{0, 2}},
+		{Round: 3, Bracket: BracketLosers, Players: [2]int{1, 3}},
+		{Round: 4, Bracket: BracketLosers, Players: [2]int{1, 2}},
+		{Round: 5, Bracket: BracketFinal, Players: [2]int{0, 1}},
+	}
+	if len(tr.Matches) != len(want) {
+		t.Fatalf("matches %+v", tr.Matches)
+	}
+	for i, m := range tr.Matches {
+		w := want[i]
+		if m.Round != w.Round || m.Bracket != w.Bracket || m.Players != w.Players {
+			t.Errorf("match %d is %+v, want %+v", i+1, m, w)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tournament")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tournament.json")
+
+	tr, err := New(DoubleElimination, names(5), sim.Rules{Points: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Save(path, tr); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Matches) != len(tr.Matches) || loaded.Rules != tr.Rules {
+		t.Errorf("loaded %+v, saved %+v", loaded, tr)
+	}
+
+	cases := []struct {
+		name string
+		json string
+	}{
+		{"unknown format", `{"Format": "swiss", "Players": ["a", "b"]}`},
+		{"one player", `{"Format": "single", "Players": ["a"]}`},
+		{"player out of range", `{"Format": "single", "Players": ["a", "b"], "Matches": [{"Round": 1, "Players": [0, 2]}]}`},
+		{"negative player", `{"Format": "single", "Players": ["a", "b"], "Matches": [{"Round": 1, "Players": [-1, 1]}]}`},
+		{"playing themselves", `{"Format": "single", "Players": ["a", "b"], "Matches": [{"Round": 1, "Players": [1, 1]}]}`},
+		{"unplayed bye", `{"Format": "single", "Players": ["a", "b", "c"], "Matches": [{"Round": 1, "Players": [2, -1]}]}`},
+		{"stranger wins", `{"Format": "single", "Players": ["a", "b", "c"], "Matches": [{"Round": 1, "Players": [0, 1], "Played": true, "Winner": 2}]}`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := ioutil.WriteFile(path, []byte(c.json), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Error("loaded without an error")
+			}
+		})
+	}
+}
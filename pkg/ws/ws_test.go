@@ -0,0 +1,298 @@
+package ws
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// frame is a frame as a client sends it, masked unless told otherwise.
+func frame(fin bool, op byte, payload []byte, masked bool) []byte {
+	var b bytes.Buffer
+	if fin {
+		op |= 0x80
+	}
+	b.WriteByte(op)
+	var maskBit byte
+	if masked {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		b.WriteByte(maskBit | byte(n))
+	case n <= 0xffff:
+		b.WriteByte(maskBit | 126)
+		binary.Write(&b, binary.BigEndian, uint16(n))
+	default:
+		b.WriteByte(maskBit | 127)
+		binary.Write(&b, binary.BigEndian, uint64(n))
+	}
+	if !masked {
+		b.Write(payload)
+		return b.Bytes()
+	}
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	b.Write(mask[:])
+	for i, c := range payload {
+		b.WriteByte(c ^ mask[i%4])
+	}
+	return b.Bytes()
+}
+
+type serverFrame struct {
+	op      byte
+	payload []byte
+	// length is the first byte of the length, which says how the rest of
+	// it was written.
+	length byte
+}
+
+// readFrame reads a frame the way a browser would.
+func readFrame(r *bufio.Reader) (serverFrame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return serverFrame{}, err
+	}
+	f := serverFrame{op: head[0] & 0x0f, length: head[1]}
+	n := uint64(head[1])
+	switch n {
+	case 126:
+		var b [2]byte
+		io.ReadFull(r, b[:])
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		io.ReadFull(r, b[:])
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	f.payload = make([]byte, n)
+	_, err := io.ReadFull(r, f.payload)
+	return f, err
+}
+
+// connect upgrades one end of a pipe, returning the other end and what the
+// server sends down it.
+func connect(t *testing.T) (*Conn, net.Conn, <-chan serverFrame) {
+	t.Helper()
+	server, client := net.Pipe()
+	go io.WriteString(client, "GET /play HTTP/1.1\r\n"+
+		"Host: localhost\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	type upgraded struct {
+		c   *Conn
+		err error
+	}
+	done := make(chan upgraded)
+	go func() {
+		c, err := Upgrade(server, bufio.NewReader(server))
+		done <- upgraded{c, err}
+	}()
+
+	r := bufio.NewReader(client)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The accept key is the one worked out in RFC 6455.
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake answered with %s %v", resp.Status, resp.Header)
+	}
+	u := <-done
+	if u.err != nil {
+		t.Fatal(u.err)
+	}
+
+	frames := make(chan serverFrame, 16)
+	go func() {
+		defer close(frames)
+		for {
+			f, err := readFrame(r)
+			if err != nil {
+				return
+			}
+			frames <- f
+		}
+	}()
+	return u.c, client, frames
+}
+
+func TestUpgradeRefused(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go io.WriteString(client, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	errs := make(chan error)
+	go func() {
+		_, err := Upgrade(server, bufio.NewReader(server))
+		errs <- err
+	}()
+	resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("plain request answered with %s", resp.Status)
+	}
+	if <-errs == nil {
+		t.Error("upgraded a plain request")
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	medium := strings.Repeat("m", 300)
+	long := strings.Repeat("l", 70000)
+	tests := []struct {
+		name   string
+		frames [][]byte
+		want   []string
+		// pongs are the payloads of the pongs expected back.
+		pongs []string
+		err   error
+	}{
+		{
+			name:   "short",
+			frames: [][]byte{frame(true, opText, []byte("hello"), true)},
+			want:   []string{"hello"},
+		},
+		{
+			name:   "16 bit length",
+			frames: [][]byte{frame(true, opText, []byte(medium), true)},
+			want:   []string{medium},
+		},
+		{
+			name:   "64 bit length",
+			frames: [][]byte{frame(true, opBinary, []byte(long), true)},
+			want:   []string{long},
+		},
+		{
+			name: "fragmented",
+			frames: [][]byte{
+				frame(false, opText, []byte("Hel"), true),
+				frame(false, opContinuation, []byte("lo, "), true),
+				frame(true, opContinuation, []byte("world"), true),
+				frame(true, opText, []byte("again"), true),
+			},
+			want: []string{"Hello, world", "again"},
+		},
+		{
+			name: "ping between fragments",
+			frames: [][]byte{
+				frame(false, opText, []byte("a"), true),
+				frame(true, opPing, []byte("are you there"), true),
+				frame(true, opPong, nil, true),
+				frame(true, opContinuation, []byte("b"), true),
+			},
+			want:  []string{"ab"},
+			pongs: []string{"are you there"},
+		},
+		{
+			name:   "close",
+			frames: [][]byte{frame(true, opText, []byte("bye"), true), frame(true, opClose, nil, true)},
+			want:   []string{"bye"},
+			err:    io.EOF,
+		},
+		{
+			name:   "too large",
+			frames: [][]byte{frame(true, opText, make([]byte, MaxMessageSize+1), true)},
+			err:    ErrTooLarge,
+		},
+		{
+			name: "too large in fragments",
+			frames: [][]byte{
+				frame(false, opText, make([]byte, MaxMessageSize/2+1), true),
+				frame(true, opContinuation, make([]byte, MaxMessageSize/2+1), true),
+			},
+			err: ErrTooLarge,
+		},
+		{
+			name:   "unmasked",
+			frames: [][]byte{frame(true, opText, []byte("hello"), false)},
+			err:    errUnmasked,
+		},
+	}
+	for _, test := range tests {
+		c, client, frames := connect(t)
+		sent := test.frames
+		go func() {
+			for _, f := range sent {
+				if _, err := client.Write(f); err != nil {
+					return
+				}
+			}
+		}()
+		for _, want := range test.want {
+			got, err := c.ReadMessage()
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+				break
+			}
+			if string(got) != want {
+				t.Errorf("%s: read %.20q (%d bytes), want %.20q (%d bytes)", test.name, got, len(got), want, len(want))
+			}
+		}
+		if test.err != nil {
+			if _, err := c.ReadMessage(); err != test.err {
+				t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+			}
+		}
+		c.conn.Close()
+
+		var pongs []string
+		closed := false
+		for f := range frames {
+			switch f.op {
+			case opPong:
+				pongs = append(pongs, string(f.payload))
+			case opClose:
+				closed = true
+			}
+		}
+		if strings.Join(pongs, ",") != strings.Join(test.pongs, ",") {
+			t.Errorf("%s: ponged %q, want %q", test.name, pongs, test.pongs)
+		}
+		if closed != (test.err == io.EOF) {
+			t.Errorf("%s: close answered %v", test.name, closed)
+		}
+		client.Close()
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	tests := []struct {
+		size   int
+		length byte
+	}{
+		{0, 0},
+		{125, 125},
+		{126, 126},
+		{0xffff, 126},
+		{0x10000, 127},
+	}
+	c, client, frames := connect(t)
+	defer client.Close()
+	for _, test := range tests {
+		sent := bytes.Repeat([]byte{'x'}, test.size)
+		errs := make(chan error)
+		go func() { errs <- c.WriteMessage(sent) }()
+		f := <-frames
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+		if f.op != opText || f.length != test.length || !bytes.Equal(f.payload, sent) {
+			t.Errorf("%d bytes sent as op %d, length %d and %d bytes, want length %d",
+				test.size, f.op, f.length, len(f.payload), test.length)
+		}
+	}
+
+	go c.Close()
+	if f := <-frames; f.op != opClose {
+		t.Errorf("closed with op %d", f.op)
+	}
+}